@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/match_signal"
+)
+
+// User metadata role that gives access to admin rpcs
+const adminRole = "admin"
+
+type adminMatchReq struct {
+	MatchID string
+}
+
+type adminMatch struct {
+	MatchID string
+	Size int32
+	Stats json.RawMessage
+}
+
+type adminListMatchesResp struct {
+	Matches []*adminMatch
+}
+
+// isAdmin checks that the rpc is called with the server key or by a user with the admin role
+func isAdmin(ctx context.Context, nk runtime.NakamaModule) (bool, error) {
+	userID, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	// Only server to server calls don't have a user
+	if userID == "" {
+		return true, nil
+	}
+
+	users, err := nk.UsersGetId(ctx, []string{userID}, nil)
+	if err != nil {
+		return false, err
+	}
+	if len(users) == 0 {
+		return false, nil
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(users[0].GetMetadata()), &metadata); err != nil {
+		return false, nil
+	}
+
+	return metadata[roleMetadataKey] == adminRole, nil
+}
+
+type rpcFunc func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error)
+
+func adminOnly(fn rpcFunc) rpcFunc {
+	return func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+		ok, err := isAdmin(ctx, nk)
+		if err != nil {
+			logger.Error("can't check admin role: %v", err)
+			return "", runtime.NewError("internal server error", 13)
+		}
+		if !ok {
+			return "", runtime.NewError("permission denied", 7)
+		}
+
+		return fn(ctx, logger, db, nk, payload)
+	}
+}
+
+// signalRpc sends the payload as the command to the match with MatchID from the payload
+func signalRpc(command match_signal.Command) rpcFunc {
+	return func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+		var req adminMatchReq
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", runtime.NewError("can't unmarshal payload", 3)
+		}
+		if req.MatchID == "" {
+			return "", runtime.NewError("MatchID is required", 3)
+		}
+
+		b, err := json.Marshal(match_signal.NewReq(command, json.RawMessage(payload)))
+		assert.NoError(err)
+
+		resp, err := nk.MatchSignal(ctx, req.MatchID, string(b))
+		if err != nil {
+			logger.Error("can't signal match %s: %v", req.MatchID, err)
+			return "", runtime.NewError("can't signal match", 5)
+		}
+
+		return resp, nil
+	}
+}
+
+// adminListMatchesRpc returns running matches with their stats
+func adminListMatchesRpc(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	matches, err := nk.MatchList(ctx, 100, true, "achikaps", nil, nil, "")
+	if err != nil {
+		logger.Error("can't list matches: %v", err)
+		return "", runtime.NewError("can't list matches", 13)
+	}
+
+	b, err := json.Marshal(match_signal.NewReq(match_signal.StatsCommand, nil))
+	assert.NoError(err)
+
+	resp := &adminListMatchesResp{make([]*adminMatch, 0, len(matches))}
+	for _, m := range matches {
+		// Match can end between listing and signaling
+		stats, err := nk.MatchSignal(ctx, m.GetMatchId(), string(b))
+		if err != nil {
+			logger.Warn("can't signal match %s: %v", m.GetMatchId(), err)
+			continue
+		}
+
+		resp.Matches = append(resp.Matches, &adminMatch{
+			MatchID: m.GetMatchId(),
+			Size: m.GetSize(),
+			Stats: json.RawMessage(stats),
+		})
+	}
+
+	out, err := json.Marshal(resp)
+	assert.NoError(err)
+
+	return string(out), nil
+}
+
+func registerAdminRpcs(initializer runtime.Initializer) error {
+	rpcs := map[string]rpcFunc{
+		"admin_list_matches": adminListMatchesRpc,
+		"admin_dump_match": signalRpc(match_signal.DumpCommand),
+		"admin_grant_materials": signalRpc(match_signal.GrantMaterialsCommand),
+		"admin_grant_units": signalRpc(match_signal.GrantUnitsCommand),
+		"admin_end_match": signalRpc(match_signal.EndCommand),
+		"admin_set_tick_rate": signalRpc(match_signal.SetTickRateCommand),
+		"admin_set_game_speed": signalRpc(match_signal.SetGameSpeedCommand),
+	}
+
+	for id, fn := range rpcs {
+		if err := initializer.RegisterRpc(id, adminOnly(fn)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
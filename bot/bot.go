@@ -0,0 +1,523 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/game_map"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/tech_tree"
+	"github.com/relby/achikaps/vec2"
+)
+
+type Difficulty uint
+
+const (
+	EasyDifficulty Difficulty = iota + 1
+	NormalDifficulty
+	HardDifficulty
+)
+
+func NewDifficulty(v uint) (Difficulty, error) {
+	switch v := Difficulty(v); v {
+	case EasyDifficulty,
+		NormalDifficulty,
+		HardDifficulty:
+		return v, nil
+	}
+
+	return 0, errors.New("invalid bot difficulty")
+}
+
+// Nodes are built in this order, every entry is the next node of the name
+var buildOrder = []model.NodeName{
+	model.GrassFieldNodeName,
+	model.WellNodeName,
+	model.SandTransitNodeName,
+	model.SandQuarryNodeName,
+	model.ResearchLabNodeName,
+	model.GrassFieldNodeName,
+	model.SeedStorageNodeName,
+	model.AphidDistillationNodeName,
+	model.RawMaterialVatNodeName,
+	model.SandTransitNodeName,
+	model.WellNodeName,
+	model.RawMaterialVatNodeName,
+	model.GrassFieldNodeName,
+	model.SeedStorageNodeName,
+	model.EggFarmNodeName,
+	model.FungusGardenNodeName,
+	model.SandTransitNodeName,
+	model.IncubatorNodeName,
+	model.RawMaterialVatNodeName,
+	model.ChitinPressNodeName,
+	model.BarracksNodeName,
+	model.PheromoneMineNodeName,
+	model.ResinTapperNodeName,
+	model.GuardOutpostNodeName,
+}
+
+// Bots build more of this node when there is not enough food for units
+const foodNodeName = model.AphidDistillationNodeName
+
+// Bot is a server side player, it owns a graph in the state like any other player
+// and sends the same messages as clients do
+type Bot struct {
+	sessionID string
+	difficulty Difficulty
+	rand *rand.Rand
+	nextDecisionTick int64
+}
+
+// New creates a bot, it has its own random generator, so replays don't depend on bots
+func New(sessionID string, difficulty Difficulty, seed uint64) *Bot {
+	return &Bot{
+		sessionID,
+		difficulty,
+		rand.New(rand.NewPCG(seed, seed)),
+		0,
+	}
+}
+
+func (b *Bot) SessionID() string {
+	return b.sessionID
+}
+
+func (b *Bot) Difficulty() Difficulty {
+	return b.difficulty
+}
+
+// Decide returns messages that the bot sends this tick
+func (b *Bot) Decide(s *match_state.State) []runtime.MatchData {
+	if s.CurrentTick < b.nextDecisionTick {
+		return nil
+	}
+	b.nextDecisionTick = s.CurrentTick + int64(math.Ceil(b.decisionIntervalMs() / s.TickMs()))
+
+	out := make([]runtime.MatchData, 0)
+	if msg, ok := b.decideBuildNode(s); ok {
+		out = append(out, msg)
+	}
+	
+	if msg, ok := b.decideResearch(s); ok {
+		out = append(out, msg)
+	}
+	
+	out = append(out, b.decideFeed(s)...)
+	out = append(out, b.decideChangeUnitTypes(s)...)
+	
+	return out
+}
+
+// decisionIntervalMs is in the game time, so bots don't get faster in sped up games
+func (b *Bot) decisionIntervalMs() float64 {
+	switch b.difficulty {
+	case EasyDifficulty:
+		return 5_000
+	case NormalDifficulty:
+		return 2_000
+	case HardDifficulty:
+		return 1_000
+	default:
+		panic("unreachable")
+	}
+}
+
+// maxBuildingNodes is how many nodes the bot builds at the same time
+func (b *Bot) maxBuildingNodes() int {
+	switch b.difficulty {
+	case EasyDifficulty:
+		return 1
+	case NormalDifficulty:
+		return 2
+	case HardDifficulty:
+		return 3
+	default:
+		panic("unreachable")
+	}
+}
+
+// maxUnitTypeChanges is how many units the bot reassigns per decision
+func (b *Bot) maxUnitTypeChanges() int {
+	switch b.difficulty {
+	case EasyDifficulty:
+		return 1
+	case NormalDifficulty:
+		return 2
+	case HardDifficulty:
+		return 4
+	default:
+		panic("unreachable")
+	}
+}
+
+// Stay away from the distance limits, so the node is not rejected because of rounding
+const (
+	minBuildDistance = config.MinNodeDistance + 0.1
+	maxBuildDistance = config.MaxNodeDistance - 0.1
+)
+
+// depositsInReach returns deposits that the node needs and that can be built on from the node
+func depositsInReach(s *match_state.State, name model.NodeName, fromNode *model.Node) []*game_map.Deposit {
+	typ, ok := model.RequiredDeposit(name)
+	if !ok {
+		return nil
+	}
+
+	out := make([]*game_map.Deposit, 0)
+	for _, d := range s.Map.Deposits {
+		dist := vec2.Distance(fromNode.Position(), d.Position)
+		if d.Type == typ && dist >= minBuildDistance && dist <= maxBuildDistance {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+type buildNodeReq struct {
+	FromNodeID model.ID
+	Name model.NodeName
+	Position vec2.Vec2
+}
+
+func (b *Bot) decideBuildNode(s *match_state.State) (runtime.MatchData, bool) {
+	playerGraph, ok := s.Graphs[b.sessionID]
+	assert.True(ok)
+
+	if len(playerGraph.BuildingNodes()) >= b.maxBuildingNodes() {
+		return nil, false
+	}
+	
+	// Materials that are lying in the storage and can be used for building
+	available := make(map[model.MaterialType]uint)
+	for _, m := range s.Materials[b.sessionID] {
+		if m.IsReserved() || m.NodeData() == nil || m.NodeData().IsInput {
+			continue
+		}
+
+		available[m.Type()] += 1
+	}
+	
+	affordable := func(name model.NodeName) bool {
+		data := model.NewNode(0, b.sessionID, s.Factions[b.sessionID], name, vec2.Vec2{}).BuildingData()
+		for t, c := range data.Materials {
+			if available[t] < c {
+				return false
+			}
+		}
+		
+		return true
+	}
+	
+	fromNodes := playerGraph.NodesByType(model.TransitNodeType, true)
+	if len(fromNodes) == 0 {
+		return nil, false
+	}
+	
+	// Nodes are planned only when the faction has them, they are researched
+	// and there is a deposit in reach if they need it
+	placeable := func(name model.NodeName) bool {
+		if !s.Factions[b.sessionID].CanBuild(name) || !s.IsUnlocked(b.sessionID, name) {
+			return false
+		}
+
+		if _, ok := model.RequiredDeposit(name); !ok {
+			return true
+		}
+
+		for _, fromNode := range fromNodes {
+			if len(depositsInReach(s, name, fromNode)) > 0 {
+				return true
+			}
+		}
+		
+		return false
+	}
+	
+	var name model.NodeName
+	if b.difficulty == EasyDifficulty {
+		// Easy bot doesn't plan, it builds anything it can afford
+		names := make([]model.NodeName, 0, len(buildOrder))
+		for _, n := range buildOrder {
+			if affordable(n) && placeable(n) {
+				names = append(names, n)
+			}
+		}
+		
+		if len(names) == 0 {
+			return nil, false
+		}
+		
+		name = names[b.rand.IntN(len(names))]
+	} else {
+		built := make(map[model.NodeName]int)
+		for _, n := range playerGraph.Nodes() {
+			built[n.Name()] += 1
+		}
+		
+		planned := make(map[model.NodeName]int)
+		for _, n := range buildOrder {
+			if !placeable(n) {
+				continue
+			}
+
+			planned[n] += 1
+			if planned[n] > built[n] {
+				name = n
+				break
+			}
+		}
+
+		// Build order is done, keep expanding
+		if name == 0 {
+			name = model.SandTransitNodeName
+		}
+
+		// Units starve without food, so one more food node goes before the build order
+		if stats := s.Stats(b.sessionID); stats.Food < stats.Population && placeable(foodNodeName) {
+			isBuilding := false
+			for _, n := range playerGraph.BuildingNodes() {
+				if n.Name() == foodNodeName {
+					isBuilding = true
+				}
+			}
+
+			if !isBuilding {
+				name = foodNodeName
+			}
+		}
+
+		if !affordable(name) {
+			return nil, false
+		}
+	}
+	
+	const attempts = 20
+	for range attempts {
+		fromNode := fromNodes[b.rand.IntN(len(fromNodes))]
+
+		var pos vec2.Vec2
+		if _, ok := model.RequiredDeposit(name); ok {
+			deposits := depositsInReach(s, name, fromNode)
+			if len(deposits) == 0 {
+				continue
+			}
+
+			pos = deposits[b.rand.IntN(len(deposits))].Position
+		} else {
+			angle := b.rand.Float64() * 2 * math.Pi
+			radius := minBuildDistance + b.rand.Float64() * (maxBuildDistance - minBuildDistance)
+			pos = fromNode.Position().Add(vec2.New(radius*math.Cos(angle), radius*math.Sin(angle)))
+		}
+		
+		n := model.NewNode(0, b.sessionID, s.Factions[b.sessionID], name, pos)
+		if err := s.CheckPlacement(fromNode, n); err != nil {
+			continue
+		}
+
+		return b.message(opcode.BuildNode, &buildNodeReq{fromNode.ID(), name, pos}), true
+	}
+	
+	return nil, false
+}
+
+type startResearchReq struct {
+	TechID model.ID
+	NodeID model.ID
+}
+
+// decideResearch starts the first available tech, easy bot picks a random one
+func (b *Bot) decideResearch(s *match_state.State) (runtime.MatchData, bool) {
+	if _, ok := s.Researches[b.sessionID]; ok {
+		return nil, false
+	}
+
+	playerGraph, ok := s.Graphs[b.sessionID]
+	assert.True(ok)
+
+	labs := playerGraph.NodesByType(model.ResearchNodeType, true)
+	if len(labs) == 0 {
+		return nil, false
+	}
+
+	techs := make([]*tech_tree.Tech, 0, len(s.TechTree.Techs))
+	for _, tech := range s.TechTree.Techs {
+		if s.TechTree.IsAvailable(tech, s.Techs[b.sessionID]) {
+			techs = append(techs, tech)
+		}
+	}
+
+	if len(techs) == 0 {
+		return nil, false
+	}
+
+	tech := techs[0]
+	if b.difficulty == EasyDifficulty {
+		tech = techs[b.rand.IntN(len(techs))]
+	}
+
+	return b.message(opcode.StartResearch, &startResearchReq{tech.ID, labs[0].ID()}), true
+}
+
+type unitCommandReq struct {
+	UnitID model.ID
+	Type model.UnitCommandType
+	NodeID model.ID
+	FromNodeID model.ID
+	ToNodeID model.ID
+	MaterialType model.MaterialType
+}
+
+// decideFeed pins a worker and a hauler of its input to a food node when units are short of food
+func (b *Bot) decideFeed(s *match_state.State) []runtime.MatchData {
+	if stats := s.Stats(b.sessionID); stats.Food >= stats.Population {
+		return nil
+	}
+
+	playerGraph, ok := s.Graphs[b.sessionID]
+	assert.True(ok)
+
+	// Units that are already sent to the nodes are counted, they can be on the way
+	workers := make(map[model.ID]int)
+	haulers := make(map[model.ID]int)
+	for _, u := range s.Units[b.sessionID] {
+		switch cmd := u.Command(); {
+		case cmd == nil:
+			if u.Type() == model.ProductionUnitType && u.Node() != nil {
+				workers[u.Node().ID()] += 1
+			}
+		case cmd.Type == model.WorkUnitCommandType:
+			workers[cmd.Data.(*model.WorkUnitCommandData).Node.ID()] += 1
+		case cmd.Type == model.HaulUnitCommandType:
+			haulers[cmd.Data.(*model.HaulUnitCommandData).ToNode.ID()] += 1
+		}
+	}
+
+	wells := playerGraph.NodesByType(model.ProductionNodeType, true)
+	wells = slices.DeleteFunc(wells, func(n *model.Node) bool { return n.Name() != model.WellNodeName })
+
+	out := make([]runtime.MatchData, 0)
+	for _, n := range playerGraph.NodesByType(model.ProductionNodeType, true) {
+		if n.Name() != foodNodeName {
+			continue
+		}
+
+		if workers[n.ID()] == 0 {
+			// Worker is taken from the node that has the most of them, the last worker of a node is not taken
+			var worker *model.Unit
+			for _, u := range sortedUnits(s.Units[b.sessionID]) {
+				if u.Type() != model.ProductionUnitType || u.Command() != nil || u.Node() == nil || workers[u.Node().ID()] < 2 {
+					continue
+				}
+
+				if worker == nil || workers[u.Node().ID()] > workers[worker.Node().ID()] {
+					worker = u
+				}
+			}
+			if worker == nil {
+				continue
+			}
+
+			out = append(out, b.message(opcode.UnitCommand, &unitCommandReq{UnitID: worker.ID(), Type: model.WorkUnitCommandType, NodeID: n.ID()}))
+			return out
+		}
+
+		if haulers[n.ID()] == 0 && len(wells) != 0 {
+			for _, u := range sortedUnits(s.Units[b.sessionID]) {
+				if u.Type() != model.TransportUnitType || u.Command() != nil {
+					continue
+				}
+
+				out = append(out, b.message(opcode.UnitCommand, &unitCommandReq{
+					UnitID: u.ID(),
+					Type: model.HaulUnitCommandType,
+					FromNodeID: wells[0].ID(),
+					ToNodeID: n.ID(),
+					MaterialType: model.DewMaterialType,
+				}))
+				return out
+			}
+		}
+	}
+
+	return out
+}
+
+// sortedUnits returns units ordered by their IDs, so bots decide the same way for the same state
+func sortedUnits(units map[model.ID]*model.Unit) []*model.Unit {
+	out := make([]*model.Unit, 0, len(units))
+	for _, id := range slices.Sorted(maps.Keys(units)) {
+		out = append(out, units[id])
+	}
+
+	return out
+}
+
+type changeUnitTypeReq struct {
+	ID model.ID
+	Type model.UnitType
+}
+
+func (b *Bot) decideChangeUnitTypes(s *match_state.State) []runtime.MatchData {
+	playerGraph, ok := s.Graphs[b.sessionID]
+	assert.True(ok)
+
+	playerUnits, ok := s.Units[b.sessionID]
+	assert.True(ok)
+	
+	counts := make(map[model.UnitType]int)
+	for _, u := range playerUnits {
+		counts[u.Type()] += 1
+	}
+	
+	// Workers per production node
+	workers := 1
+	if b.difficulty == HardDifficulty {
+		workers = 2
+	}
+	
+	wanted := map[model.UnitType]int{
+		model.BuilderUnitType: min(len(playerGraph.BuildingNodes()), 3),
+		model.ProductionUnitType: len(playerGraph.NodesByType(model.ProductionNodeType, true)) * workers,
+	}
+	wanted[model.TransportUnitType] = max(wanted[model.ProductionUnitType], 2)
+	
+	out := make([]runtime.MatchData, 0)
+	for _, id := range slices.Sorted(maps.Keys(playerUnits)) {
+		if len(out) >= b.maxUnitTypeChanges() {
+			break
+		}
+
+		u := playerUnits[id]
+		if u.Type() != model.IdleUnitType {
+			continue
+		}
+		
+		for _, t := range []model.UnitType{model.BuilderUnitType, model.ProductionUnitType, model.TransportUnitType} {
+			if counts[t] < wanted[t] {
+				counts[t] += 1
+				out = append(out, b.message(opcode.ChangeUnitType, &changeUnitTypeReq{u.ID(), t}))
+				break
+			}
+		}
+	}
+	
+	return out
+}
+
+func (b *Bot) message(opCode opcode.OpCode, req any) runtime.MatchData {
+	data, err := json.Marshal(req)
+	assert.NoError(err)
+
+	return opcode.NewMessage(b.sessionID, opCode, data)
+}
@@ -0,0 +1,353 @@
+// achikaps-sim runs a match without Nakama and prints economy stats of every player
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/relby/achikaps/bot"
+	"github.com/relby/achikaps/match_signal"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/opcode_handler"
+	"github.com/relby/achikaps/queue"
+	"github.com/relby/achikaps/replay"
+	"github.com/relby/achikaps/rules"
+)
+
+var unitColumns = []struct{
+	Name string
+	Type model.UnitType
+}{
+	{"idle", model.IdleUnitType},
+	{"production", model.ProductionUnitType},
+	{"builder", model.BuilderUnitType},
+	{"transport", model.TransportUnitType},
+}
+
+var materialColumns = []struct{
+	Name string
+	Type model.MaterialType
+}{
+	{"grass", model.GrassMaterialType},
+	{"sand", model.SandMaterialType},
+	{"dew", model.DewMaterialType},
+	{"seed", model.SeedMaterialType},
+	{"sugar", model.SugarMaterialType},
+	{"juice", model.JuiceMaterialType},
+	{"chitin", model.ChitinMaterialType},
+	{"egg", model.EggMaterialType},
+	{"pheromone", model.PheromoneMaterialType},
+	{"amber", model.AmberMaterialType},
+}
+
+// maxTicks caps runs without a winner so the simulator always stops
+const maxTicks int64 = 100_000
+
+type statsRow struct {
+	Tick int64
+	SessionID string
+	Stats *match_state.PlayerStats
+}
+
+type options struct {
+	RulesPath string
+	Queue string
+	Players int
+	Ticks int64
+	Seed uint64
+	ScriptPath string
+	ReplayPath string
+	RecordPath string
+	Seek int64
+	Format string
+	Every int64
+	Bots int
+	BotDifficulty uint
+	Faction uint
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.RulesPath, "rules", "", "path to the rules JSON file, default rules are used if empty")
+	flag.StringVar(&opts.Queue, "queue", "", "use rules of the queue: ranked_1v1, casual_ffa or teams_2v2, ignored if rules are set")
+	flag.IntVar(&opts.Players, "players", 2, "number of players")
+	flag.Int64Var(&opts.Ticks, "ticks", 0, "number of ticks to run, 0 means until someone wins or the tick limit")
+	flag.Uint64Var(&opts.Seed, "seed", 1, "seed of the match")
+	flag.StringVar(&opts.ScriptPath, "script", "", "path to the file with scripted commands, JSON object per line")
+	flag.StringVar(&opts.ReplayPath, "replay", "", "path to the replay file, it replaces rules, players, seed and script")
+	flag.StringVar(&opts.RecordPath, "record", "", "path to write the replay of the run to")
+	flag.Int64Var(&opts.Seek, "seek", 0, "run to the tick and print the snapshot of the state instead of stats")
+	flag.StringVar(&opts.Format, "format", "csv", "output format: csv or json")
+	flag.Int64Var(&opts.Every, "every", 1, "print stats every N ticks")
+	flag.IntVar(&opts.Bots, "bots", 0, "number of bots added to the players, ignored for replays")
+	flag.UintVar(&opts.BotDifficulty, "bot-difficulty", uint(bot.NormalDifficulty), "difficulty of bots: 1 - easy, 2 - normal, 3 - hard")
+	flag.UintVar(&opts.Faction, "faction", uint(model.DefaultFaction), "faction of players: 1 - harvester, 2 - soldier, bots take factions in turn")
+	flag.Parse()
+	
+	if err := run(&opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(opts *options) error {
+	if opts.Every <= 0 {
+		return fmt.Errorf("invalid stats interval: %d", opts.Every)
+	}
+
+	var (
+		state *match_state.State
+		entries map[int64][]*replay.Entry
+		bots []*bot.Bot
+		ticks = opts.Ticks
+	)
+	if opts.ReplayPath != "" {
+		rep, err := replay.Load(opts.ReplayPath)
+		if err != nil {
+			return err
+		}
+		
+		state, err = match_state.New(rep.SessionIDs, rep.Factions, rep.Rules, rep.Seed)
+		if err != nil {
+			return err
+		}
+		entries = rep.EntriesByTick()
+		if ticks == 0 {
+			ticks = rep.EndTick
+		}
+	} else {
+		if opts.Players < 0 || opts.Bots < 0 || opts.Players + opts.Bots == 0 {
+			return fmt.Errorf("invalid number of players: %d, bots: %d", opts.Players, opts.Bots)
+		}
+		
+		difficulty, err := bot.NewDifficulty(opts.BotDifficulty)
+		if err != nil {
+			return err
+		}
+
+		faction, err := model.NewFaction(opts.Faction)
+		if err != nil {
+			return err
+		}
+
+		r := rules.Default()
+		if opts.Queue != "" {
+			q, err := queue.NewQueue(opts.Queue)
+			if err != nil {
+				return err
+			}
+			
+			r = q.Rules()
+		}
+		if opts.RulesPath != "" {
+			var err error
+			r, err = rules.Load(opts.RulesPath)
+			if err != nil {
+				return err
+			}
+		}
+		
+		sessionIDs := make([]string, 0, opts.Players)
+		factions := make(map[string]model.Faction, opts.Players + opts.Bots)
+		for i := range opts.Players {
+			sessionIDs = append(sessionIDs, "player" + strconv.Itoa(i + 1))
+			factions[sessionIDs[i]] = faction
+		}
+		
+		// Bot commands are recorded, so replays are played back without bots
+		for i := range opts.Bots {
+			b := bot.New("bot" + strconv.Itoa(i + 1), difficulty, opts.Seed + uint64(i) + 1)
+			bots = append(bots, b)
+			sessionIDs = append(sessionIDs, b.SessionID())
+			factions[b.SessionID()] = model.Factions[i % len(model.Factions)]
+		}
+
+		state, err = match_state.New(sessionIDs, factions, r, opts.Seed)
+		if err != nil {
+			return err
+		}
+
+		entries = map[int64][]*replay.Entry{}
+		if opts.ScriptPath != "" {
+			var err error
+			entries, err = loadScript(opts.ScriptPath, sessionIDs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	
+	if opts.Seek > 0 {
+		ticks = opts.Seek
+	}
+	if ticks == 0 {
+		ticks = maxTicks
+	}
+
+	for _, sessionID := range state.SessionIDs {
+		state.Presences[sessionID] = &simPresence{sessionID}
+	}
+	
+	var writeStats func() error
+	switch {
+	case opts.Seek > 0:
+		writeStats = func() error { return nil }
+	case opts.Format == "csv":
+		w := csv.NewWriter(os.Stdout)
+		defer w.Flush()
+
+		header := []string{"tick", "session_id", "nodes", "built_nodes"}
+		for _, c := range unitColumns {
+			header = append(header, c.Name)
+		}
+		for _, c := range materialColumns {
+			header = append(header, c.Name)
+		}
+		header = append(header, "population", "max_population", "starving", "food")
+		if err := w.Write(header); err != nil {
+			return fmt.Errorf("can't write stats: %w", err)
+		}
+
+		writeStats = func() error {
+			for _, sessionID := range state.SessionIDs {
+				stats := state.Stats(sessionID)
+
+				row := []string{
+					strconv.FormatInt(state.CurrentTick, 10),
+					sessionID,
+					strconv.Itoa(stats.Nodes),
+					strconv.Itoa(stats.BuiltNodes),
+				}
+				for _, c := range unitColumns {
+					row = append(row, strconv.Itoa(stats.Units[c.Type]))
+				}
+				for _, c := range materialColumns {
+					row = append(row, strconv.Itoa(stats.Materials[c.Type]))
+				}
+				row = append(row, strconv.Itoa(stats.Population), strconv.Itoa(stats.MaxPopulation), strconv.Itoa(stats.StarvingUnits), strconv.Itoa(stats.Food))
+				
+				if err := w.Write(row); err != nil {
+					return fmt.Errorf("can't write stats: %w", err)
+				}
+			}
+			
+			return nil
+		}
+	case opts.Format == "json":
+		enc := json.NewEncoder(os.Stdout)
+		writeStats = func() error {
+			for _, sessionID := range state.SessionIDs {
+				if err := enc.Encode(statsRow{state.CurrentTick, sessionID, state.Stats(sessionID)}); err != nil {
+					return fmt.Errorf("can't write stats: %w", err)
+				}
+			}
+			
+			return nil
+		}
+	default:
+		return fmt.Errorf("invalid format: %s", opts.Format)
+	}
+	
+	dispatcher := &simDispatcher{&state.CurrentTick}
+	for state.CurrentTick < ticks {
+		// Entries are applied in the order they were recorded, pauses expire between them as in the match loop
+		for _, e := range entries[state.CurrentTick] {
+			// Only signals that changed the match are recorded, so they can't fail
+			if e.Signal != nil {
+				match_signal.Handle(e.Signal.Data, state)
+				continue
+			}
+
+			if e.PauseExpiry != nil {
+				state.ExpirePause()
+				continue
+			}
+
+			cmd := e.Command
+			msg := &simMatchData{
+				simPresence{cmd.SessionID},
+				int64(cmd.OpCode),
+				cmd.Data,
+				state.CurrentTick,
+			}
+
+			state.Replay.Record(state.CurrentTick, cmd.OpCode, cmd.SessionID, cmd.Data)
+
+			if err := opcode_handler.Handle(cmd.OpCode, dispatcher, msg, state); err != nil {
+				return fmt.Errorf("tick %d: %w", state.CurrentTick, err)
+			}
+		}
+		
+		for _, b := range bots {
+			for _, msg := range b.Decide(state) {
+				opCode, err := opcode.NewOpCode(msg.GetOpCode())
+				if err != nil {
+					return fmt.Errorf("tick %d: %w", state.CurrentTick, err)
+				}
+
+				state.Replay.Record(state.CurrentTick, opCode, msg.GetSessionId(), msg.GetData())
+
+				if err := opcode_handler.Handle(opCode, dispatcher, msg, state); err != nil {
+					return fmt.Errorf("tick %d: %w", state.CurrentTick, err)
+				}
+			}
+		}
+
+		// Pauses of scripts and bots have no recorded expiry, the simulator doesn't wait for them
+		if state.Paused {
+			state.ExpirePause()
+		}
+
+		state.Tick()
+		
+		// Nobody listens to the client updates
+		for _, sessionID := range state.SessionIDs {
+			state.RespsWithOpcode[sessionID] = state.RespsWithOpcode[sessionID][:0]
+		}
+		state.Events = state.Events[:0]
+		
+		if state.CurrentTick % opts.Every == 0 {
+			if err := writeStats(); err != nil {
+				return err
+			}
+		}
+
+		if sessionID, ok := state.Winner(); ok {
+			if state.CurrentTick % opts.Every != 0 {
+				if err := writeStats(); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(os.Stderr, "%s won on tick %d\n", sessionID, state.CurrentTick)
+			break
+		}
+	}
+	
+	if opts.Seek > 0 {
+		if err := json.NewEncoder(os.Stdout).Encode(state.Snapshot()); err != nil {
+			return fmt.Errorf("can't write snapshot: %w", err)
+		}
+	}
+	
+	if opts.RecordPath != "" {
+		state.Replay.EndTick = state.CurrentTick
+
+		b, err := json.Marshal(state.Replay)
+		if err != nil {
+			return fmt.Errorf("can't marshal replay: %w", err)
+		}
+
+		if err := os.WriteFile(opts.RecordPath, b, 0o644); err != nil {
+			return fmt.Errorf("can't write replay: %w", err)
+		}
+	}
+	
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relby/achikaps/bot"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/replay"
+)
+
+// seekTicks is long enough for bots to build, produce and trade units
+const seekTicks = 3000
+
+func botOptions() *options {
+	return &options{
+		Players: 0,
+		Seed: 7,
+		Seek: seekTicks,
+		Format: "csv",
+		Every: 1,
+		Bots: 2,
+		BotDifficulty: uint(bot.HardDifficulty),
+		Faction: uint(model.DefaultFaction),
+	}
+}
+
+// runSnapshot runs the simulator and returns the snapshot it prints on the seek tick
+func runSnapshot(t *testing.T, opts *options) []byte {
+	t.Helper()
+
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatalf("can't create stdout file: %v", err)
+	}
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	err = run(opts)
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	b, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatalf("can't read stdout file: %v", err)
+	}
+	if len(b) == 0 {
+		t.Fatal("snapshot is empty")
+	}
+
+	return b
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	first := runSnapshot(t, botOptions())
+	second := runSnapshot(t, botOptions())
+
+	if !bytes.Equal(first, second) {
+		t.Error("snapshots of runs with the same seed differ")
+	}
+
+	opts := botOptions()
+	opts.Seed += 1
+	if other := runSnapshot(t, opts); bytes.Equal(first, other) {
+		t.Error("snapshots of runs with different seeds are the same")
+	}
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	recordPath := filepath.Join(t.TempDir(), "replay.json")
+
+	opts := botOptions()
+	opts.RecordPath = recordPath
+	recorded := runSnapshot(t, opts)
+
+	replayed := runSnapshot(t, &options{
+		ReplayPath: recordPath,
+		Seek: seekTicks,
+		Format: "csv",
+		Every: 1,
+	})
+
+	if !bytes.Equal(recorded, replayed) {
+		t.Error("snapshot of the replay differs from the recorded run")
+	}
+}
+
+// Second pause is made on the same tick after the first one has timed out,
+// it's only valid if the expiry is applied between them
+func TestReplayAppliesPauseExpiryInOrder(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.json")
+	runSnapshot(t, &options{
+		Players: 2,
+		Seed: 1,
+		Seek: 1,
+		RecordPath: basePath,
+		Format: "csv",
+		Every: 1,
+		BotDifficulty: uint(bot.NormalDifficulty),
+		Faction: uint(model.DefaultFaction),
+	})
+
+	rep, err := replay.Load(basePath)
+	if err != nil {
+		t.Fatalf("can't load replay: %v", err)
+	}
+
+	rep.Commands = []*replay.Command{
+		{Tick: 5, Seq: 0, OpCode: opcode.Pause, SessionID: "player1", Data: []byte("{}")},
+		{Tick: 5, Seq: 2, OpCode: opcode.Pause, SessionID: "player2", Data: []byte("{}")},
+	}
+	rep.PauseExpiries = []*replay.PauseExpiry{{Tick: 5, Seq: 1}}
+	rep.NextSeq = 3
+
+	b, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatalf("can't marshal replay: %v", err)
+	}
+	pausePath := filepath.Join(dir, "pause.json")
+	if err := os.WriteFile(pausePath, b, 0o644); err != nil {
+		t.Fatalf("can't write replay: %v", err)
+	}
+
+	recordPath := filepath.Join(dir, "record.json")
+	runSnapshot(t, &options{
+		ReplayPath: pausePath,
+		RecordPath: recordPath,
+		Seek: 10,
+		Format: "csv",
+		Every: 1,
+	})
+
+	got, err := replay.Load(recordPath)
+	if err != nil {
+		t.Fatalf("can't load recorded replay: %v", err)
+	}
+
+	// Both pauses succeed, so the second one expires before the tick as well
+	if len(got.PauseExpiries) != 2 {
+		t.Fatalf("pause expiries = %d, want 2", len(got.PauseExpiries))
+	}
+	if got.PauseExpiries[0].Seq != 1 || got.PauseExpiries[1].Seq != 3 {
+		t.Errorf("pause expiries are out of order: %d, %d", got.PauseExpiries[0].Seq, got.PauseExpiries[1].Seq)
+	}
+}
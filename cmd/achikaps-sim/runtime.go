@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// simPresence stands for a player, there's no real session in the simulator
+type simPresence struct {
+	sessionID string
+}
+
+func (p *simPresence) GetHidden() bool { return false }
+func (p *simPresence) GetPersistence() bool { return false }
+func (p *simPresence) GetUsername() string { return p.sessionID }
+func (p *simPresence) GetStatus() string { return "" }
+func (p *simPresence) GetReason() runtime.PresenceReason { return runtime.PresenceReasonUnknown }
+func (p *simPresence) GetUserId() string { return p.sessionID }
+func (p *simPresence) GetSessionId() string { return p.sessionID }
+func (p *simPresence) GetNodeId() string { return "" }
+
+// simMatchData is a scripted message fed to the opcode handlers
+type simMatchData struct {
+	simPresence
+	opCode int64
+	data []byte
+	receiveTime int64
+}
+
+func (d *simMatchData) GetOpCode() int64 { return d.opCode }
+func (d *simMatchData) GetData() []byte { return d.data }
+func (d *simMatchData) GetReliable() bool { return true }
+func (d *simMatchData) GetReceiveTime() int64 { return d.receiveTime }
+
+// simDispatcher drops every message except errors, which are reported to stderr
+type simDispatcher struct {
+	tick *int64
+}
+
+func (d *simDispatcher) BroadcastMessage(opCode int64, data []byte, presences []runtime.Presence, sender runtime.Presence, reliable bool) error {
+	var resp struct {
+		Error string
+	}
+	if err := json.Unmarshal(data, &resp); err == nil && resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "tick %d: op code %d: %s\n", *d.tick, opCode, resp.Error)
+	}
+
+	return nil
+}
+
+func (d *simDispatcher) BroadcastMessageDeferred(opCode int64, data []byte, presences []runtime.Presence, sender runtime.Presence, reliable bool) error {
+	return d.BroadcastMessage(opCode, data, presences, sender, reliable)
+}
+
+func (d *simDispatcher) MatchKick(presences []runtime.Presence) error {
+	return nil
+}
+
+func (d *simDispatcher) MatchLabelUpdate(label string) error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/replay"
+)
+
+// scriptCommand is a client message that is sent on the given tick
+type scriptCommand struct {
+	Tick int64
+	// Index of the player in the match
+	Player int
+	OpCode int64
+	Data json.RawMessage
+}
+
+// loadScript reads commands from a file with a JSON object per line
+func loadScript(path string, sessionIDs []string) (map[int64][]*replay.Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open script: %w", err)
+	}
+	defer f.Close()
+
+	out := make(map[int64][]*replay.Entry)
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var cmd scriptCommand
+		if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+			return nil, fmt.Errorf("can't unmarshal line %d: %w", line, err)
+		}
+		
+		if cmd.Player < 0 || cmd.Player >= len(sessionIDs) {
+			return nil, fmt.Errorf("invalid player on line %d: %d", line, cmd.Player)
+		}
+		
+		opCode, err := opcode.NewOpCode(cmd.OpCode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid op code on line %d: %w", line, err)
+		}
+
+		out[cmd.Tick] = append(out[cmd.Tick], &replay.Entry{Command: &replay.Command{
+			Tick: cmd.Tick,
+			OpCode: opCode,
+			SessionID: sessionIDs[cmd.Player],
+			Data: cmd.Data,
+		}})
+	}
+	
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read script: %w", err)
+	}
+
+	return out, nil
+}
@@ -1,12 +1,36 @@
 package config
 
 const (
+	// Default number of ticks per second, match loop is called with this rate
 	TickRate int = 10
+	MinGameSpeed float64 = 0.1
+	MaxGameSpeed float64 = 10.0
 	
 	NodeRadius float64 = 1.0
 	PlayersStartRadius float64 = 30.0
 	MinNodeDistance = NodeRadius * 2
 	MaxNodeDistance = NodeRadius * 5
 
-	UnitSpeed float64 = 0.135
+	// Distance per second
+	UnitSpeed float64 = 1.35
+	BuildTimeMs float64 = 1_000.0
+
+	// Production nodes on boosting deposits work this many times faster
+	DepositBoost float64 = 2.0
+
+	// Neutral node is captured when the player holds the claim with this many units for this time
+	CaptureUnits int = 3
+	CaptureTimeMs float64 = 20_000.0
+
+	// Every unit eats one food material in this time
+	UpkeepIntervalMs float64 = 120_000.0
+	// Units that missed a meal move this many times slower
+	StarvingSpeed float64 = 0.5
+	// Units die after missing this many meals in a row
+	StarvationMeals uint = 3
+
+	// Population cap of the player without housing nodes
+	BasePopulation int = 20
+	// Every housing node raises the population cap by this many units
+	HousingPopulation int = 10
 )
\ No newline at end of file
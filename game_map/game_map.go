@@ -0,0 +1,424 @@
+package game_map
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/rules"
+	"github.com/relby/achikaps/vec2"
+)
+
+const (
+	// Features that don't fit after this many attempts are skipped for every player
+	placeAttempts = 100
+	// Maps that fail the fairness check are generated again this many times
+	generateAttempts = 10
+
+	// Obstacles and sites are not placed this close to the starts, so players always have room to expand
+	startClearRadius = config.MaxNodeDistance * 2
+	// Space between features, so nodes can be built between them
+	featureGap = config.NodeRadius * 2
+
+	minObstacleRadius = config.NodeRadius * 2
+	maxObstacleRadius = config.NodeRadius * 4
+	depositRadius = config.NodeRadius * 2
+
+	// Distances of the features are compared with this precision in the fairness check
+	epsilon = 1e-6
+)
+
+// Deposits are placed in this order, so every map has all of them when there are enough deposits
+var DepositTypes = []model.DepositType{
+	model.SandDepositType,
+	model.DewDepositType,
+	model.AmberDepositType,
+}
+
+// Nodes can't be built without these deposits, so every map has them, see model.RequiredDeposit
+var RequiredDepositTypes = []model.DepositType{
+	model.AmberDepositType,
+}
+
+type SiteType uint
+
+const (
+	AncientAmberSiteType SiteType = iota + 1
+	AphidColonySiteType
+)
+
+// Sites are placed in this order
+var SiteTypes = []SiteType{
+	AncientAmberSiteType,
+	AphidColonySiteType,
+}
+
+// Obstacle is terrain that nodes and edges can't cross
+type Obstacle struct {
+	Position vec2.Vec2
+	Radius float64
+}
+
+// Deposit boosts or unlocks production nodes that are built on it
+type Deposit struct {
+	Type model.DepositType
+	Position vec2.Vec2
+	Radius float64
+}
+
+// NodeName returns the name of the neutral node that is placed on the site
+func (t SiteType) NodeName() model.NodeName {
+	switch t {
+	case AncientAmberSiteType:
+		return model.AncientAmberNodeName
+	case AphidColonySiteType:
+		return model.AphidColonyNodeName
+	default:
+		panic("unreachable")
+	}
+}
+
+// Site is a place of a neutral node between players
+type Site struct {
+	Type SiteType
+	Position vec2.Vec2
+	Radius float64
+}
+
+type Map struct {
+	// Positions of root nodes in the order of players
+	Starts []vec2.Vec2
+	Obstacles []*Obstacle
+	Deposits []*Deposit
+	Sites []*Site
+}
+
+func onCircle(i, n int, r float64) vec2.Vec2 {
+	angle := float64(i) * 2.0 * math.Pi / float64(n)
+
+	return vec2.New(
+		r * math.Cos(angle),
+		r * math.Sin(angle),
+	)
+}
+
+func rotate(v vec2.Vec2, angle float64) vec2.Vec2 {
+	sin, cos := math.Sincos(angle)
+
+	return vec2.New(
+		v.X*cos - v.Y*sin,
+		v.X*sin + v.Y*cos,
+	)
+}
+
+// empty creates a map with starts only, it's fair for any number of players
+func empty(players int, r *rules.Rules) *Map {
+	m := &Map{
+		Starts: make([]vec2.Vec2, 0, players),
+		Obstacles: make([]*Obstacle, 0),
+		Deposits: make([]*Deposit, 0),
+		Sites: make([]*Site, 0),
+	}
+
+	for i := range players {
+		m.Starts = append(m.Starts, onCircle(i, players, r.PlayersStartRadius))
+	}
+
+	return m
+}
+
+// fallback creates a map with starts and required deposits only, it's used when generated maps fail the check
+func fallback(players int, r *rules.Rules) *Map {
+	m := empty(players, r)
+
+	for i, typ := range RequiredDepositTypes {
+		p := m.Starts[0].Add(onCircle(i, len(RequiredDepositTypes), config.MaxNodeDistance * 2))
+		for _, p := range m.symmetric(p) {
+			m.Deposits = append(m.Deposits, &Deposit{typ, p, depositRadius})
+		}
+	}
+
+	return m
+}
+
+// Generate creates the map from the random generator, so the same seed gives the same map.
+// Features are generated for the first player and rotated for the others, so all starts are the same
+func Generate(players int, r *rules.Rules, rnd *rand.Rand) *Map {
+	if players == 0 {
+		return empty(players, r)
+	}
+
+	for range generateAttempts {
+		m := generate(players, r, rnd)
+		if err := m.Check(); err == nil {
+			return m
+		}
+	}
+
+	return fallback(players, r)
+}
+
+// depositTypes returns types of deposits that are placed for every player,
+// required deposits are added if the rules have too few deposits
+func depositTypes(count uint) []model.DepositType {
+	out := make([]model.DepositType, 0, count)
+	for i := range count {
+		out = append(out, DepositTypes[int(i) % len(DepositTypes)])
+	}
+
+	for _, t := range RequiredDepositTypes {
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func generate(players int, r *rules.Rules, rnd *rand.Rand) *Map {
+	m := empty(players, r)
+
+	for range r.Obstacles {
+		for range placeAttempts {
+			radius := minObstacleRadius + rnd.Float64() * (maxObstacleRadius - minObstacleRadius)
+			ps := m.symmetric(m.nearStart(rnd, startClearRadius + radius, r.PlayersStartRadius))
+
+			if !m.fits(ps, radius, startClearRadius) {
+				continue
+			}
+
+			for _, p := range ps {
+				m.Obstacles = append(m.Obstacles, &Obstacle{p, radius})
+			}
+			break
+		}
+	}
+
+	for _, typ := range depositTypes(r.Deposits) {
+		for range placeAttempts {
+			ps := m.symmetric(m.nearStart(rnd, config.MaxNodeDistance * 1.5, config.MaxNodeDistance * 3.5))
+
+			if !m.fits(ps, depositRadius, config.MaxNodeDistance) {
+				continue
+			}
+
+			for _, p := range ps {
+				m.Deposits = append(m.Deposits, &Deposit{typ, p, depositRadius})
+			}
+			break
+		}
+	}
+
+	for i := range r.NeutralSites {
+		typ := SiteTypes[int(i) % len(SiteTypes)]
+		for range placeAttempts {
+			ps := m.symmetric(m.betweenStarts(rnd))
+
+			if !m.fits(ps, config.NodeRadius, startClearRadius) {
+				continue
+			}
+
+			for _, p := range ps {
+				m.Sites = append(m.Sites, &Site{typ, p, config.NodeRadius})
+			}
+			break
+		}
+	}
+
+	return m
+}
+
+// nearStart returns a random position around the start of the first player
+func (m *Map) nearStart(rnd *rand.Rand, minDist, maxDist float64) vec2.Vec2 {
+	angle := rnd.Float64() * 2 * math.Pi
+	dist := minDist + rnd.Float64() * (maxDist - minDist)
+
+	return m.Starts[0].Add(vec2.New(dist*math.Cos(angle), dist*math.Sin(angle)))
+}
+
+// betweenStarts returns a random position between the starts of the first and the second players
+func (m *Map) betweenStarts(rnd *rand.Rand) vec2.Vec2 {
+	middle := rotate(m.Starts[0], math.Pi / float64(len(m.Starts)))
+	scale := 0.5 + rnd.Float64() * 0.5
+
+	return middle.MulScalar(scale)
+}
+
+// symmetric returns the position rotated to every start
+func (m *Map) symmetric(p vec2.Vec2) []vec2.Vec2 {
+	out := make([]vec2.Vec2, 0, len(m.Starts))
+	for i := range m.Starts {
+		out = append(out, rotate(p, float64(i) * 2.0 * math.Pi / float64(len(m.Starts))))
+	}
+
+	return out
+}
+
+// fits checks if features of the radius can be placed at the positions
+func (m *Map) fits(ps []vec2.Vec2, radius float64, clearRadius float64) bool {
+	for i, p := range ps {
+		for _, start := range m.Starts {
+			if vec2.Distance(p, start) < clearRadius + radius {
+				return false
+			}
+		}
+
+		for _, o := range m.Obstacles {
+			if vec2.Distance(p, o.Position) < o.Radius + radius + featureGap {
+				return false
+			}
+		}
+
+		for _, d := range m.Deposits {
+			if vec2.Distance(p, d.Position) < d.Radius + radius + featureGap {
+				return false
+			}
+		}
+
+		for _, s := range m.Sites {
+			if vec2.Distance(p, s.Position) < s.Radius + radius + featureGap {
+				return false
+			}
+		}
+
+		for _, other := range ps[:i] {
+			if vec2.Distance(p, other) < radius * 2 + featureGap {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// view is the map as it's seen from one start
+type view struct {
+	obstacles []float64
+	deposits map[model.DepositType][]float64
+	sites map[SiteType][]float64
+}
+
+func (m *Map) view(i int) *view {
+	start := m.Starts[i]
+
+	v := &view{
+		obstacles: make([]float64, 0, len(m.Obstacles)),
+		deposits: make(map[model.DepositType][]float64, len(DepositTypes)),
+		sites: make(map[SiteType][]float64, len(SiteTypes)),
+	}
+
+	// Bigger obstacles are further from the start, so both distances and sizes are compared
+	for _, o := range m.Obstacles {
+		v.obstacles = append(v.obstacles, vec2.Distance(start, o.Position) - o.Radius, vec2.Distance(start, o.Position) + o.Radius)
+	}
+	for _, d := range m.Deposits {
+		v.deposits[d.Type] = append(v.deposits[d.Type], vec2.Distance(start, d.Position))
+	}
+	for _, s := range m.Sites {
+		v.sites[s.Type] = append(v.sites[s.Type], vec2.Distance(start, s.Position))
+	}
+
+	slices.Sort(v.obstacles)
+	for _, ds := range v.deposits {
+		slices.Sort(ds)
+	}
+	for _, ds := range v.sites {
+		slices.Sort(ds)
+	}
+
+	return v
+}
+
+func equalDistances(a, b []float64) bool {
+	return slices.EqualFunc(a, b, func(x, y float64) bool {
+		return math.Abs(x - y) < epsilon
+	})
+}
+
+// Check makes sure that the map is fair, every player has the same features at the same distances
+// from the start, nothing blocks the starts and all required deposits are placed
+func (m *Map) Check() error {
+	for i, start := range m.Starts {
+		for _, o := range m.Obstacles {
+			if vec2.Distance(start, o.Position) < startClearRadius + o.Radius {
+				return fmt.Errorf("obstacle is too close to the start %d", i)
+			}
+		}
+
+		for _, s := range m.Sites {
+			if vec2.Distance(start, s.Position) < startClearRadius + s.Radius {
+				return fmt.Errorf("site is too close to the start %d", i)
+			}
+		}
+	}
+
+	if len(m.Starts) == 0 {
+		return nil
+	}
+
+	for _, t := range RequiredDepositTypes {
+		if !slices.ContainsFunc(m.Deposits, func(d *Deposit) bool { return d.Type == t }) {
+			return fmt.Errorf("required deposit %d is not placed", t)
+		}
+	}
+
+	want := m.view(0)
+	for i := 1; i < len(m.Starts); i++ {
+		got := m.view(i)
+
+		if !equalDistances(want.obstacles, got.obstacles) {
+			return fmt.Errorf("obstacles of the start %d differ from the start 0", i)
+		}
+
+		for _, t := range DepositTypes {
+			if !equalDistances(want.deposits[t], got.deposits[t]) {
+				return fmt.Errorf("deposits of the start %d differ from the start 0", i)
+			}
+		}
+
+		for _, t := range SiteTypes {
+			if !equalDistances(want.sites[t], got.sites[t]) {
+				return fmt.Errorf("sites of the start %d differ from the start 0", i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// NodeIntersectsAny checks if the node intersects any obstacle,
+// sites are checked by the match state, because they become nodes
+func (m *Map) NodeIntersectsAny(n *model.Node) bool {
+	for _, o := range m.Obstacles {
+		if vec2.Distance(n.Position(), o.Position) < o.Radius + n.Radius() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EdgeIntersectsAny checks if the edge from n1 to n2 crosses any obstacle
+func (m *Map) EdgeIntersectsAny(n1, n2 *model.Node) bool {
+	for _, o := range m.Obstacles {
+		if vec2.DistanceToSegment(o.Position, n1.Position(), n2.Position()) < o.Radius {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DepositAt returns the deposit the position is on
+func (m *Map) DepositAt(pos vec2.Vec2) (*Deposit, bool) {
+	for _, d := range m.Deposits {
+		if vec2.Distance(pos, d.Position) <= d.Radius {
+			return d, true
+		}
+	}
+
+	return nil, false
+}
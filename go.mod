@@ -7,9 +7,7 @@ require (
 	github.com/dominikbraun/graph v0.23.0
 	github.com/gammazero/deque v1.0.0
 	github.com/heroiclabs/nakama-common v1.36.0
+	google.golang.org/protobuf v1.36.4
 )
 
-require (
-	github.com/google/go-cmp v0.6.0 // indirect
-	google.golang.org/protobuf v1.36.4 // indirect
-)
+require github.com/google/go-cmp v0.6.0 // indirect
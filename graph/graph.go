@@ -2,7 +2,9 @@ package graph
 
 import (
 	"fmt"
+	"maps"
 	"math"
+	"slices"
 
 	"github.com/dominikbraun/graph"
 	"github.com/relby/achikaps/assert"
@@ -87,9 +89,21 @@ func (g *Graph) AddNodeFrom(n1, n2 *model.Node) (error) {
 	return nil
 }
 
-func (g *Graph) NodesByType(typ model.NodeType, isBuilt bool) []*model.Node {
+// SortedNodes returns nodes ordered by their IDs
+func (g *Graph) SortedNodes() []*model.Node {
 	ns := g.Nodes()
 	
+	out := make([]*model.Node, 0, len(ns))
+	for _, id := range slices.Sorted(maps.Keys(ns)) {
+		out = append(out, ns[id])
+	}
+	
+	return out
+}
+
+func (g *Graph) NodesByType(typ model.NodeType, isBuilt bool) []*model.Node {
+	ns := g.SortedNodes()
+	
 	out := make([]*model.Node, 0, len(ns))
 
 	for _, n := range ns {
@@ -104,7 +118,7 @@ func (g *Graph) NodesByType(typ model.NodeType, isBuilt bool) []*model.Node {
 }
 
 func (g *Graph) BuildingNodes() []*model.Node {
-	ns := g.Nodes()
+	ns := g.SortedNodes()
 	
 	out := make([]*model.Node, 0, len(ns))
 
@@ -118,19 +132,67 @@ func (g *Graph) BuildingNodes() []*model.Node {
 }
 
 // FindShortestPath computes the shortest path from source node to target node
-// using Dijkstra's algorithm. It returns a slice of node IDs representing the path
-// and the total distance of the path.
+// using Dijkstra's algorithm. It returns a slice of nodes representing the path.
+// Distances between the nodes are used as weights, ties are broken by node IDs
+// so that the same graph always gives the same path.
 func (g *Graph) FindShortestPath(source, target *model.Node) []*model.Node {
-	ids, err := graph.ShortestPath(g.g, source.ID(), target.ID())
-	assert.NoError(err)
+	am := g.AdjacencyMap()
+
+	dists := make(map[model.ID]float64, len(am))
+	prevs := make(map[model.ID]model.ID, len(am))
+	visited := make(map[model.ID]bool, len(am))
+	for id := range am {
+		dists[id] = math.Inf(1)
+	}
+	dists[source.ID()] = 0
 	
-	out := make([]*model.Node, 0, len(ids))
-	for _, id := range ids {
+	ids := slices.Sorted(maps.Keys(am))
+	for {
+		// Graphs are small, so linear search of the closest node is fine
+		current, found := model.ID(0), false
+		for _, id := range ids {
+			if visited[id] || math.IsInf(dists[id], 1) {
+				continue
+			}
+			
+			if !found || dists[id] < dists[current] {
+				current, found = id, true
+			}
+		}
+		
+		// Every node that's reachable is visited
+		assert.True(found)
+
+		if current == target.ID() {
+			break
+		}
+		visited[current] = true
+		
+		currentNode, err := g.Node(current)
+		assert.NoError(err)
+
+		for _, id := range slices.Sorted(maps.Keys(am[current])) {
+			n, err := g.Node(id)
+			assert.NoError(err)
+
+			dist := dists[current] + currentNode.DistanceTo(n)
+			if dist < dists[id] {
+				dists[id] = dist
+				prevs[id] = current
+			}
+		}
+	}
+	
+	out := []*model.Node{target}
+	for id := target.ID(); id != source.ID(); {
+		id = prevs[id]
+
 		n, err := g.Node(id)
 		assert.NoError(err)
-		
+
 		out = append(out, n)
 	}
+	slices.Reverse(out)
 	
 	return out
 }
@@ -199,6 +261,34 @@ func (g *Graph) NodeIntersectsAny(n *model.Node) bool {
 	return false
 }
 
+// EdgeIntersectsAny checks if the edge from n1 to n2 crosses any existing edges or nodes in the graph.
+// Edges and nodes that the new edge starts from are skipped, because they always touch it
 func (g *Graph) EdgeIntersectsAny(n1, n2 *model.Node) bool {
+	for _, graphNode := range g.Nodes() {
+		if graphNode == n1 || graphNode == n2 {
+			continue
+		}
+
+		if vec2.DistanceToSegment(graphNode.Position(), n1.Position(), n2.Position()) < graphNode.Radius() {
+			return true
+		}
+	}
+
+	for _, edge := range g.Edges() {
+		sourceNode, err := g.Node(edge.Source)
+		assert.NoError(err)
+
+		targetNode, err := g.Node(edge.Target)
+		assert.NoError(err)
+
+		if sourceNode == n1 || targetNode == n1 {
+			continue
+		}
+
+		if vec2.SegmentsIntersect(n1.Position(), n2.Position(), sourceNode.Position(), targetNode.Position()) {
+			return true
+		}
+	}
+
 	return false
 }
\ No newline at end of file
@@ -4,147 +4,138 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"maps"
-	"math"
 	"math/rand/v2"
 	"slices"
 
-	"github.com/heroiclabs/nakama-common/rtapi"
 	"github.com/heroiclabs/nakama-common/runtime"
 	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/bot"
 	"github.com/relby/achikaps/config"
-	"github.com/relby/achikaps/graph"
+	"github.com/relby/achikaps/match_signal"
 	"github.com/relby/achikaps/match_state"
 	"github.com/relby/achikaps/model"
 	"github.com/relby/achikaps/opcode"
 	"github.com/relby/achikaps/opcode_handler"
-	"github.com/relby/achikaps/vec2"
-	"github.com/relby/achikaps/win_condition"
+	"github.com/relby/achikaps/queue"
+	"github.com/relby/achikaps/rating"
 )
 
-type Match struct{}
+const replayCollection = "replays"
 
-func onCircle(i, n int, r float64) vec2.Vec2 {
-	angle := float64(i) * 2.0 * math.Pi / float64(n)
+// Matchmaker numeric property with the faction of the player, default faction is used if it's not set
+const factionProperty = "faction"
 
-	return vec2.New(
-		r * math.Cos(angle),
-		r * math.Sin(angle),
-	)
+// Join metadata that makes the presence a spectator
+const (
+	roleMetadataKey = "role"
+	spectatorRole = "spectator"
+)
+
+type Match struct{
+	queue queue.Queue
+	bots []*bot.Bot
+	// User IDs of players by their session IDs
+	userIDs map[string]string
 }
 
 func (m *Match) MatchInit(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, params map[string]interface{}) (interface{}, int, string) {
 	// TODO: handle errors
 	players := params["players"].([]runtime.MatchmakerEntry)
+	botDifficulties, _ := params["bots"].([]bot.Difficulty)
+	q, ok := params["queue"].(queue.Queue)
+	if !ok {
+		q = queue.DefaultQueue
+	}
+	m.queue = q
 
-	state := &match_state.State{
-		Presences:   make(map[string]runtime.Presence, len(players)),
+	seed := rand.Uint64()
 
-		Graphs: make(map[string]*graph.Graph, len(players)),
-		NextNodeIDs: make(map[string]model.ID, len(players)),
+	sessionIDs := make([]string, 0, len(players) + len(botDifficulties))
+	factions := make(map[string]model.Faction, len(players) + len(botDifficulties))
+	m.userIDs = make(map[string]string, len(players))
+	for _, p := range byParty(players) {
+		sessionIDs = append(sessionIDs, p.GetPresence().GetSessionId())
+		m.userIDs[p.GetPresence().GetSessionId()] = p.GetPresence().GetUserId()
+		factions[p.GetPresence().GetSessionId()] = entryFaction(p)
+	}
+	
+	for i, d := range botDifficulties {
+		b := bot.New(fmt.Sprintf("bot-%d", i + 1), d, seed + uint64(i) + 1)
+		m.bots = append(m.bots, b)
+		sessionIDs = append(sessionIDs, b.SessionID())
+		factions[b.SessionID()] = model.Factions[i % len(model.Factions)]
+	}
 
-		Units: make(map[string]map[model.ID]*model.Unit, len(players)),
-		NextUnitIDs: make(map[string]model.ID),
+	state, err := match_state.New(sessionIDs, factions, q.Rules(), seed)
+	if err != nil {
+		logger.Error("can't create match state: %v", err)
+		return nil, 0, ""
+	}
 
-		Materials: make(map[string]map[model.ID]*model.Material, len(players)),
-		NextMaterialIDs: make(map[string]model.ID, len(players)),
-		
-		WinCondition: win_condition.New(model.JuiceMaterialType, 100),
+	tickRate := config.TickRate // 1 tick per second = 1 MatchLoop func invocations per second
+	label := "achikaps"
+	return state, tickRate, label
+}
 
-		RespsWithOpcode: make(map[string][]*opcode.RespWithOpCode, len(players)),
+// entryFaction returns the faction of the matchmaker entry, the property is validated by the hook
+func entryFaction(e runtime.MatchmakerEntry) model.Faction {
+	v, _ := e.GetProperties()[factionProperty].(float64)
+
+	f, err := model.NewFaction(uint(v))
+	if err != nil {
+		return model.DefaultFaction
 	}
-	
-	for i, p := range players {
-		sessionID := p.GetPresence().GetSessionId()
-
-		root := model.NewNode(
-			model.ID(1),
-			sessionID,
-			model.SandTransitNodeName,
-			onCircle(i, len(players), config.PlayersStartRadius),
-		)
-		root.BuildFully()
-
-		g := graph.New(root)
-
-		state.Graphs[sessionID] = g
-
-		for i := range 2 {
-			var n *model.Node
-			for {
-				angle := rand.Float64() * 2 * math.Pi
-				radius := config.MinNodeDistance + rand.Float64() * (config.MaxNodeDistance - config.MinNodeDistance)
-				pos := vec2.New(
-					root.Position().X + radius*math.Cos(angle),
-					root.Position().Y + radius*math.Sin(angle),
-				)
-				
-				n = model.NewNode(
-					model.ID(i + 2),
-					sessionID,
-					model.SandTransitNodeName,
-					pos,
-				)
-				n.BuildFully()
-				
-				if !g.NodeIntersectsAny(n) {
-					break
-				}
-			}
-			
-			err := g.AddNodeFrom(root, n)
-			assert.NoError(err)
-		}
 
-		state.NextNodeIDs[sessionID] = model.ID(4)
-		
-		state.Units[sessionID] = make(map[model.ID]*model.Unit)
-		c := model.ID(1)
-		for _, t := range []model.UnitType{model.IdleUnitType, model.BuilderUnitType, model.ProductionUnitType, model.TransportUnitType} {
-			for range 4 {
-				state.Units[sessionID][c] = model.NewUnit(c, sessionID, t, root)
-				c += 1
-			}
+	return f
+}
+
+// byParty orders players so that members of a party are next to each other,
+// teams are made from consecutive players, bigger parties go first so they aren't split
+func byParty(players []runtime.MatchmakerEntry) []runtime.MatchmakerEntry {
+	groups := make([][]runtime.MatchmakerEntry, 0, len(players))
+	partyGroups := make(map[string]int)
+	for _, p := range players {
+		partyID := p.GetPartyId()
+		if partyID == "" {
+			groups = append(groups, []runtime.MatchmakerEntry{p})
+			continue
 		}
-		state.NextUnitIDs[sessionID] = c
-
-		state.Materials[sessionID] = make(map[model.ID]*model.Material)
-		c = model.ID(1)
-		for _, t := range []model.MaterialType{model.GrassMaterialType, model.SandMaterialType, model.DewMaterialType, model.SeedMaterialType, model.SugarMaterialType, model.JuiceMaterialType, model.ChitinMaterialType, model.EggMaterialType, model.PheromoneMaterialType, model.AmberMaterialType} {
-			for range 30 {
-				state.Materials[sessionID][c] = model.NewMaterial(c, sessionID, t, root, false)
-				c += 1
-			}
+
+		i, ok := partyGroups[partyID]
+		if !ok {
+			i = len(groups)
+			partyGroups[partyID] = i
+			groups = append(groups, nil)
 		}
-		state.NextMaterialIDs[sessionID] = c
-
-		// state.Materials[sessionID] = make(map[model.ID]*model.Material, 28)
-		// c := 1
-		// for range 20 {
-		// 	state.Materials[sessionID][model.ID(c)] = model.NewMaterial(model.ID(c), sessionID, model.GrassMaterialType, root, false)
-		// 	c += 1
-		// }
-		// for range 6 {
-		// 	state.Materials[sessionID][model.ID(c)] = model.NewMaterial(model.ID(c), sessionID, model.SandMaterialType, root, false)
-		// 	c += 1
-		// }
-		// for range 2 {
-		// 	state.Materials[sessionID][model.ID(c)] = model.NewMaterial(model.ID(c), sessionID, model.DewMaterialType, root, false)
-		// 	c += 1
-		// }
-		
-		// state.NextMaterialIDs[sessionID] = model.ID(c)
+		groups[i] = append(groups[i], p)
 	}
 
-	tickRate := config.TickRate // 1 tick per second = 1 MatchLoop func invocations per second
-	label := "achikaps"
-	return state, tickRate, label
+	slices.SortStableFunc(groups, func(a, b []runtime.MatchmakerEntry) int {
+		return len(b) - len(a)
+	})
+
+	return slices.Concat(groups...)
 }
 
 func (m *Match) MatchJoinAttempt(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presence runtime.Presence, metadata map[string]string) (interface{}, bool, string) {
-	ok := true
+	matchState, ok := state.(*match_state.State)
+	if !ok {
+		logger.Error("state not a valid lobby state object")
+		return nil, false, ""
+	}
+
+	// Players are known from the matchmaker, everyone else can only watch
+	if matchState.IsPlayer(presence.GetSessionId()) {
+		return state, true, ""
+	}
+
+	if metadata[roleMetadataKey] == spectatorRole {
+		return state, true, ""
+	}
 
-	return state, ok, ""
+	return state, false, "not a player of the match"
 }
 
 func (m *Match) MatchJoin(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
@@ -154,41 +145,72 @@ func (m *Match) MatchJoin(ctx context.Context, logger runtime.Logger, db *sql.DB
 		return nil
 	}
 
+	playerJoined := false
+	spectators := make([]runtime.Presence, 0, len(presences))
 	for _, p := range presences {
 		sessionID := p.GetSessionId()
-		matchState.Presences[sessionID] = p
+		if matchState.IsPlayer(sessionID) {
+			matchState.Presences[sessionID] = p
+			playerJoined = true
+		} else {
+			matchState.Spectators[sessionID] = p
+			spectators = append(spectators, p)
+		}
 	}
 
-	resp := &opcode.InitialStateResp{}
+	// Every player gets the state as it's seen by the team, spectators see the whole state.
+	// Only new spectators need the state if no player has joined
+	receivers := spectators
+	if playerJoined {
+		receivers = slices.Collect(maps.Values(matchState.Spectators))
+		for _, sessionID := range matchState.SessionIDs {
+			p, ok := matchState.Presences[sessionID]
+			if !ok {
+				continue
+			}
 
-	resp.Nodes = make(map[string]map[model.ID]*model.Node, len(matchState.Graphs))
-	resp.Connections = make(map[string]map[model.ID][]model.ID, len(matchState.Graphs))
-	for uID, g := range matchState.Graphs {
-		resp.Nodes[uID] = g.Nodes()
+			if err := sendSnapshot(dispatcher, matchState.SnapshotFor(sessionID), []runtime.Presence{p}); err != nil {
+				logger.Error(err.Error())
+				return nil
+			}
+		}
+	}
 
-		am := g.AdjacencyMap()
-		resp.Connections[uID] = make(map[model.ID][]model.ID, len(am))
-		for k, v := range am {
-			resp.Connections[uID][k] = slices.Collect(maps.Keys(v))
+	if len(receivers) > 0 {
+		if err := sendSnapshot(dispatcher, matchState.Snapshot(), receivers); err != nil {
+			logger.Error(err.Error())
+			return nil
 		}
 	}
 
-	resp.Units = matchState.Units
-	resp.Materials = matchState.Materials
-	resp.WinCondition = matchState.WinCondition
+	return matchState
+}
 
-	respBytes, err := json.Marshal(resp)
+// broadcastResp sends the resp to the presences, nil presences mean everyone
+func broadcastResp(dispatcher runtime.MatchDispatcher, resp any, opCode opcode.OpCode, presences []runtime.Presence) error {
+	b, err := json.Marshal(resp)
 	if err != nil {
-		logger.Error("can't marshal state: %w", err)
-		return nil
+		return fmt.Errorf("can't marshal resp: %w", err)
 	}
 
-	if err := dispatcher.BroadcastMessage(int64(opcode.InitialState), respBytes, nil, nil, true); err != nil {
-		logger.Error("can't broadcast message state: %w", err)
-		return nil
+	if err := dispatcher.BroadcastMessage(int64(opCode), b, presences, nil, true); err != nil {
+		return fmt.Errorf("can't broadcast message: %w", err)
 	}
 
-	return matchState
+	return nil
+}
+
+func sendSnapshot(dispatcher runtime.MatchDispatcher, snapshot *opcode.InitialStateResp, receivers []runtime.Presence) error {
+	respBytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("can't marshal state: %w", err)
+	}
+
+	if err := dispatcher.BroadcastMessage(int64(opcode.InitialState), respBytes, receivers, nil, true); err != nil {
+		return fmt.Errorf("can't broadcast message state: %w", err)
+	}
+
+	return nil
 }
 
 func (m *Match) MatchLeave(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
@@ -201,6 +223,7 @@ func (m *Match) MatchLeave(ctx context.Context, logger runtime.Logger, db *sql.D
 	// TODO: research if we need to delete only presences or all data
 	for _, p := range presences {
 		delete(matchState.Presences, p.GetSessionId())
+		delete(matchState.Spectators, p.GetSessionId())
 	}
 
 	return matchState
@@ -212,33 +235,109 @@ func (m *Match) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB
 		logger.Error("state not a valid lobby state object")
 		return nil
 	}
+	
+	if matchState.Ended {
+		if err := saveReplay(ctx, nk, matchState); err != nil {
+			logger.Error("can't save replay: %v", err)
+		}
+
+		logger.Info("match is ended by admin")
+		return nil
+	}
 
 	for _, msg := range messages {
 		logger.Info("got message: %s", string(msg.GetData()))
-		opCode, err := opcode.NewOpCode(msg.GetOpCode())
-		if err != nil {
-			logger.Error("invalid op code: %v", err)
-			return nil
+		// Spectators can't affect the match
+		if !matchState.IsPlayer(msg.GetSessionId()) {
+			logger.Warn("message from spectator %s is ignored", msg.GetSessionId())
+			continue
 		}
 
-		if err := opcode_handler.Handle(opCode, dispatcher, msg, matchState); err != nil {
+		if err := handleMessage(dispatcher, msg, matchState); err != nil {
 			logger.Error(err.Error())
 			return nil
 		}
 	}
 	
-	matchState.Tick()
+	for _, msg := range matchState.TakeInjected() {
+		if err := handleMessage(dispatcher, msg, matchState); err != nil {
+			logger.Error(err.Error())
+			return nil
+		}
+	}
 	
-	for _, p := range matchState.Presences {
-		sessionID := p.GetSessionId()
-		
+	winner, won := "", false
+	if matchState.Paused {
+		matchState.AdvancePause()
+	} else {
+		// Bots go through the same handlers as clients, so their messages are recorded too
+		for _, b := range m.bots {
+			for _, msg := range b.Decide(matchState) {
+				if err := handleMessage(dispatcher, msg, matchState); err != nil {
+					logger.Error(err.Error())
+					return nil
+				}
+			}
+		}
+
+		// Tick rate of the state can differ from the tick rate of the match
+		for range matchState.TicksDue() {
+			matchState.Tick()
+
+			if winner, won = matchState.Winner(); won {
+				break
+			}
+		}
+	}
+	
+	for _, e := range matchState.Events {
+		if e.SessionID == "" || !matchState.FogOfWar {
+			if err := broadcastResp(dispatcher, e.Resp, e.OpCode, nil); err != nil {
+				logger.Error(err.Error())
+				return nil
+			}
+			continue
+		}
+
+		if presences, ok := matchState.Recipients(e.SessionID); ok {
+			if err := broadcastResp(dispatcher, e.Resp, e.OpCode, presences); err != nil {
+				logger.Error(err.Error())
+				return nil
+			}
+		}
+
+		if enemies := matchState.Enemies(e.SessionID); len(enemies) > 0 {
+			if err := broadcastResp(dispatcher, e.Hidden, e.OpCode, enemies); err != nil {
+				logger.Error(err.Error())
+				return nil
+			}
+		}
+	}
+	matchState.Events = matchState.Events[:0]
+	
+	for _, sessionID := range matchState.SessionIDs {
 		respsWithOpcode := matchState.RespsWithOpcode[sessionID]
 		if len(respsWithOpcode) == 0 {
 			continue
 		}
 		
+		// Bots and players that left don't have presences, but their updates are still broadcasted
+		var p runtime.Presence
+		if presence, ok := matchState.Presences[sessionID]; ok {
+			p = presence
+		}
+		
+		presences, ok := matchState.Recipients(sessionID)
+		
 		for _, rwo := range respsWithOpcode {
+			if !ok {
+				break
+			}
+
 			resp, opCode := rwo.Resp, rwo.OpCode
+			if e, isTeamEvent := resp.(interface{ SetTeam(uint) }); isTeamEvent {
+				e.SetTeam(matchState.Team(sessionID))
+			}
 
 			b, err := json.Marshal(resp)
 			if err != nil {
@@ -246,7 +345,7 @@ func (m *Match) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB
 				return nil
 			}
 
-			if err := dispatcher.BroadcastMessage(int64(opCode), b, nil, p, true); err != nil {
+			if err := dispatcher.BroadcastMessage(int64(opCode), b, presences, p, true); err != nil {
 				logger.Error("can't broadcast message: %w", err)
 				return nil
 			}
@@ -255,40 +354,126 @@ func (m *Match) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB
 		matchState.RespsWithOpcode[sessionID] = matchState.RespsWithOpcode[sessionID][:0]
 	}
 
-	for sessionID, playerMaterials := range matchState.Materials {
-		c := 0
-		for _, m := range playerMaterials {
-			if m.Type() == matchState.WinCondition.MaterialType {
-				c += 1
-			}
+	if won {
+		ratings, err := m.updateRatings(ctx, nk, winner)
+		if err != nil {
+			logger.Error("can't update ratings: %v", err)
 		}
-		
-		if c >= matchState.WinCondition.Count {
-			b, err := json.Marshal(opcode.NewWinResp(sessionID))
-			if err != nil {
-				logger.Error("can't unmarshal state: %w", err)
-				return nil
-			}
 
-			if err := dispatcher.BroadcastMessage(int64(opcode.Win), b, nil, matchState.Presences[sessionID], true); err != nil {
-				logger.Error("can't broadcast message: %w", err)
-				return nil
-			}
-			
-			// This indicate that the match is over
+		b, err := json.Marshal(opcode.NewWinResp(winner, matchState.Team(winner), ratings))
+		if err != nil {
+			logger.Error("can't unmarshal state: %w", err)
+			return nil
+		}
+
+		if err := dispatcher.BroadcastMessage(int64(opcode.Win), b, nil, matchState.Presences[winner], true); err != nil {
+			logger.Error("can't broadcast message: %w", err)
 			return nil
 		}
+		
+		if err := saveReplay(ctx, nk, matchState); err != nil {
+			logger.Error("can't save replay: %v", err)
+		}
+		
+		// This indicate that the match is over
+		return nil
 	}
 
 	return matchState
 }
 
+// updateRatings applies the result of the match to ratings of players,
+// only ranked matches without bots are rated
+func (m *Match) updateRatings(ctx context.Context, nk runtime.NakamaModule, winner string) (map[string]*rating.Change, error) {
+	if !m.queue.Config().IsRanked || len(m.bots) > 0 || len(m.userIDs) < 2 {
+		return nil, nil
+	}
+
+	userIDs := make([]string, 0, len(m.userIDs))
+	for _, userID := range m.userIDs {
+		userIDs = append(userIDs, userID)
+	}
+
+	ratings, err := rating.Load(ctx, nk, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	bySessionID := make(map[string]*rating.Rating, len(m.userIDs))
+	for sessionID, userID := range m.userIDs {
+		bySessionID[sessionID] = ratings[userID]
+	}
+
+	changes := rating.Update(bySessionID, winner)
+
+	if err := rating.Save(ctx, nk, ratings); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// handleMessage records the message to the replay and handles it
+func handleMessage(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	opCode, err := opcode.NewOpCode(msg.GetOpCode())
+	if err != nil {
+		return fmt.Errorf("invalid op code: %w", err)
+	}
+
+	state.Replay.Record(state.CurrentTick, opCode, msg.GetSessionId(), msg.GetData())
+
+	return opcode_handler.Handle(opCode, dispatcher, msg, state)
+}
+
 func (m *Match) MatchTerminate(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, graceSeconds int) interface{} {
+	matchState, ok := state.(*match_state.State)
+	if !ok {
+		logger.Error("state not a valid lobby state object")
+		return nil
+	}
+
+	if err := saveReplay(ctx, nk, matchState); err != nil {
+		logger.Error("can't save replay: %v", err)
+	}
+
 	return state
 }
 
+// saveReplay writes the replay of the match to the storage, it's owned by the system user
+func saveReplay(ctx context.Context, nk runtime.NakamaModule, state *match_state.State) error {
+	matchID, ok := ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
+	if !ok {
+		return fmt.Errorf("no match id in context")
+	}
+
+	state.Replay.EndTick = state.CurrentTick
+
+	b, err := json.Marshal(state.Replay)
+	if err != nil {
+		return fmt.Errorf("can't marshal replay: %w", err)
+	}
+	
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection: replayCollection,
+		Key: matchID,
+		Value: string(b),
+		PermissionRead: 0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		return fmt.Errorf("can't write replay to the storage: %w", err)
+	}
+	
+	return nil
+}
+
 func (m *Match) MatchSignal(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, data string) (interface{}, string) {
-	return state, "signal received: " + data
+	matchState, ok := state.(*match_state.State)
+	if !ok {
+		logger.Error("state not a valid lobby state object")
+		return nil, ""
+	}
+
+	return matchState, match_signal.Handle(data, matchState)
 }
 
 func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, initializer runtime.Initializer) error {
@@ -299,30 +484,131 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
-	initializer.RegisterBeforeRt("MatchmakerAdd", func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, in *rtapi.Envelope) (*rtapi.Envelope, error) {
-		message, ok := in.Message.(*rtapi.Envelope_MatchmakerAdd)
-		if !ok {
-			return nil, runtime.NewError("internal server error", 13)
-		}
-
-		message.MatchmakerAdd.Query = "*"
-		message.MatchmakerAdd.MinCount = 2
-		message.MatchmakerAdd.MaxCount = 6
-
-		return in, nil
-	})
+	if err := initializer.RegisterBeforeRt("MatchmakerAdd", matchmakerAddHook); err != nil {
+		logger.Error("unable to register matchmaker add hook: %v", err)
+		return err
+	}
 
-	if err := initializer.RegisterMatchmakerMatched(func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, entries []runtime.MatchmakerEntry) (string, error) {
-		matchID, err := nk.MatchCreate(ctx, "achikaps", map[string]interface{}{"players": entries})
-		if err != nil {
-			return "", runtime.NewError("unable to create match", 13)
-		}
+	if err := initializer.RegisterMatchmakerOverride(matchmakerOverride); err != nil {
+		logger.Error("unable to register matchmaker override: %v", err)
+		return err
+	}
 
-		return matchID, nil
-	}); err != nil {
+	if err := initializer.RegisterMatchmakerMatched(matchmakerMatchedHook); err != nil {
 		logger.Error("unable to register matchmaker matched hook: %v", err)
 		return err
 	}
+	
+	if err := initializer.RegisterRpc("create_bot_match", createBotMatchRpc); err != nil {
+		logger.Error("unable to register create bot match rpc: %v", err)
+		return err
+	}
+	
+	if err := registerAdminRpcs(initializer); err != nil {
+		logger.Error("unable to register admin rpcs: %v", err)
+		return err
+	}
 
 	return nil
 }
+
+
+type createBotMatchReq struct {
+	Bots uint
+	Difficulty uint
+	// Faction of the caller, default faction if empty
+	Faction uint
+	// Rules of the queue are used, default queue if empty
+	Queue string
+}
+
+type createBotMatchResp struct {
+	MatchID string
+}
+
+// soloEntry stands for the player that creates a match with bots, bypassing the matchmaker
+type soloEntry struct {
+	presence runtime.Presence
+	faction model.Faction
+}
+
+func (e *soloEntry) GetPresence() runtime.Presence { return e.presence }
+func (e *soloEntry) GetTicket() string { return "" }
+func (e *soloEntry) GetProperties() map[string]interface{} {
+	return map[string]interface{}{factionProperty: float64(e.faction)}
+}
+func (e *soloEntry) GetPartyId() string { return "" }
+
+// soloPresence is the presence of the rpc caller
+type soloPresence struct {
+	userID string
+	sessionID string
+	username string
+}
+
+func (p *soloPresence) GetHidden() bool { return false }
+func (p *soloPresence) GetPersistence() bool { return true }
+func (p *soloPresence) GetUsername() string { return p.username }
+func (p *soloPresence) GetStatus() string { return "" }
+func (p *soloPresence) GetReason() runtime.PresenceReason { return runtime.PresenceReasonUnknown }
+func (p *soloPresence) GetUserId() string { return p.userID }
+func (p *soloPresence) GetSessionId() string { return p.sessionID }
+func (p *soloPresence) GetNodeId() string { return "" }
+
+// createBotMatchRpc creates a match of the caller against bots, it should be called through the socket
+func createBotMatchRpc(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	sessionID, _ := ctx.Value(runtime.RUNTIME_CTX_SESSION_ID).(string)
+	username, _ := ctx.Value(runtime.RUNTIME_CTX_USERNAME).(string)
+	if userID == "" || sessionID == "" {
+		return "", runtime.NewError("rpc should be called through the socket", 3)
+	}
+
+	var req createBotMatchReq
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", runtime.NewError("can't unmarshal payload", 3)
+	}
+	
+	q := queue.DefaultQueue
+	if req.Queue != "" {
+		var err error
+		q, err = queue.NewQueue(req.Queue)
+		if err != nil {
+			return "", runtime.NewError("invalid queue", 3)
+		}
+	}
+	
+	if req.Bots == 0 || int(req.Bots) >= q.Config().MaxCount {
+		return "", runtime.NewError("invalid number of bots", 3)
+	}
+
+	d, err := bot.NewDifficulty(req.Difficulty)
+	if err != nil {
+		return "", runtime.NewError("invalid bot difficulty", 3)
+	}
+	
+	f := model.DefaultFaction
+	if req.Faction != 0 {
+		f, err = model.NewFaction(req.Faction)
+		if err != nil {
+			return "", runtime.NewError("invalid faction", 3)
+		}
+	}
+	
+	bots := make([]bot.Difficulty, 0, req.Bots)
+	for range req.Bots {
+		bots = append(bots, d)
+	}
+
+	players := []runtime.MatchmakerEntry{&soloEntry{&soloPresence{userID, sessionID, username}, f}}
+	matchID, err := nk.MatchCreate(ctx, "achikaps", map[string]interface{}{"players": players, "bots": bots, "queue": q})
+	if err != nil {
+		logger.Error("unable to create match: %v", err)
+		return "", runtime.NewError("unable to create match", 13)
+	}
+	
+	b, err := json.Marshal(createBotMatchResp{matchID})
+	assert.NoError(err)
+
+	return string(b), nil
+}
\ No newline at end of file
@@ -0,0 +1,30 @@
+package match_signal
+
+import (
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type dumpResp struct {
+	Tick int64
+	TickRate int
+	GameSpeed float64
+	Seed uint64
+	SessionIDs []string
+	RoleQuotas map[string]map[model.UnitType]uint
+	State *opcode.InitialStateResp
+}
+
+// DumpHandler returns the whole state of the match
+func DumpHandler(data []byte, state *match_state.State) (any, error) {
+	return &dumpResp{
+		Tick: state.CurrentTick,
+		TickRate: state.TickRate,
+		GameSpeed: state.GameSpeed,
+		Seed: state.Seed,
+		SessionIDs: state.SessionIDs,
+		RoleQuotas: state.RoleQuotas,
+		State: state.Snapshot(),
+	}, nil
+}
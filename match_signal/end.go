@@ -0,0 +1,16 @@
+package match_signal
+
+import (
+	"github.com/relby/achikaps/match_state"
+)
+
+type endResp struct {
+	Tick int64
+}
+
+// EndHandler ends the match on the next match loop, the replay is saved as usual
+func EndHandler(data []byte, state *match_state.State) (any, error) {
+	state.Ended = true
+
+	return &endResp{state.CurrentTick}, nil
+}
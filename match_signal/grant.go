@@ -0,0 +1,81 @@
+package match_signal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+)
+
+type grantMaterialsReq struct {
+	SessionID string
+	NodeID uint
+	MaterialType uint
+	Count int
+}
+
+type grantMaterialsResp struct {
+	Materials []*model.Material
+}
+
+// GrantMaterialsHandler creates materials on the node of the player
+func GrantMaterialsHandler(data []byte, state *match_state.State) (any, error) {
+	var req grantMaterialsReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NodeID: %w", err)
+	}
+
+	typ, err := model.NewMaterialType(req.MaterialType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaterialType: %w", err)
+	}
+
+	materials, err := state.GrantMaterials(req.SessionID, nodeID, typ, req.Count)
+	if err != nil {
+		return nil, fmt.Errorf("can't grant materials: %w", err)
+	}
+
+	return &grantMaterialsResp{materials}, nil
+}
+
+type grantUnitsReq struct {
+	SessionID string
+	NodeID uint
+	UnitType uint
+	Count int
+}
+
+type grantUnitsResp struct {
+	Units []*model.Unit
+}
+
+// GrantUnitsHandler creates units on the node of the player
+func GrantUnitsHandler(data []byte, state *match_state.State) (any, error) {
+	var req grantUnitsReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NodeID: %w", err)
+	}
+
+	typ, err := model.NewUnitType(req.UnitType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UnitType: %w", err)
+	}
+
+	units, err := state.GrantUnits(req.SessionID, nodeID, typ, req.Count)
+	if err != nil {
+		return nil, fmt.Errorf("can't grant units: %w", err)
+	}
+
+	return &grantUnitsResp{units}, nil
+}
@@ -0,0 +1,46 @@
+package match_signal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/opcode_handler"
+)
+
+type injectReq struct {
+	SessionID string
+	OpCode int64
+	Data json.RawMessage
+}
+
+type injectResp struct {
+	// Message is handled before this tick
+	Tick int64
+}
+
+// InjectHandler sends the message on behalf of the player, it's handled and recorded like a client message.
+// Player may be disconnected, handlers reply only to presences that exist, the same way as for bots
+func InjectHandler(data []byte, state *match_state.State) (any, error) {
+	var req injectReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	opCode, err := opcode.NewOpCode(req.OpCode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpCode: %w", err)
+	}
+
+	// Server events can't be injected, unknown op codes end the match
+	if _, ok := opcode_handler.Handlers[opCode]; !ok {
+		return nil, fmt.Errorf("op code %d is not a client message", opCode)
+	}
+
+	if err := state.Inject(req.SessionID, opCode, req.Data); err != nil {
+		return nil, fmt.Errorf("can't inject message: %w", err)
+	}
+
+	return &injectResp{state.CurrentTick}, nil
+}
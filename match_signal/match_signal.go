@@ -0,0 +1,111 @@
+package match_signal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/match_state"
+)
+
+type Command string
+
+const (
+	StatsCommand Command = "stats"
+	DumpCommand Command = "dump"
+	GrantMaterialsCommand Command = "grant_materials"
+	GrantUnitsCommand Command = "grant_units"
+	EndCommand Command = "end"
+	SetTickRateCommand Command = "set_tick_rate"
+	PauseCommand Command = "pause"
+	ResumeCommand Command = "resume"
+	InjectCommand Command = "inject"
+	SnapshotCommand Command = "snapshot"
+	SetGameSpeedCommand Command = "set_game_speed"
+)
+
+type Handler func(data []byte, state *match_state.State) (any, error)
+
+var Handlers = map[Command]Handler{
+	StatsCommand: StatsHandler,
+	DumpCommand: DumpHandler,
+	GrantMaterialsCommand: GrantMaterialsHandler,
+	GrantUnitsCommand: GrantUnitsHandler,
+	EndCommand: EndHandler,
+	SetTickRateCommand: SetTickRateHandler,
+	PauseCommand: PauseHandler,
+	ResumeCommand: ResumeHandler,
+	InjectCommand: InjectHandler,
+	SnapshotCommand: SnapshotHandler,
+	SetGameSpeedCommand: SetGameSpeedHandler,
+}
+
+// Commands that change the simulation, they are recorded to the replay.
+// Injected messages are recorded when they are handled
+var recorded = map[Command]bool{
+	GrantMaterialsCommand: true,
+	GrantUnitsCommand: true,
+	SetTickRateCommand: true,
+	SetGameSpeedCommand: true,
+	PauseCommand: true,
+	ResumeCommand: true,
+}
+
+// Req is the signal sent to the match
+type Req struct {
+	Command Command
+	Data json.RawMessage
+}
+
+// Resp is the result of the signal, Data is the response of the command, it's set only if there is no Error
+type Resp struct {
+	Command Command
+	Tick int64
+	Data any `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+func NewReq(command Command, data json.RawMessage) *Req {
+	return &Req{
+		command,
+		data,
+	}
+}
+
+// Handle executes the signal inside the match loop and returns the JSON response
+func Handle(data string, state *match_state.State) string {
+	var req Req
+	resp := &Resp{Tick: state.CurrentTick}
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		resp.Error = fmt.Sprintf("can't unmarshal signal: %v", err)
+	} else {
+		resp.Command = req.Command
+		resp.Data, err = handle(&req, data, state)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	assert.NoError(err)
+
+	return string(b)
+}
+
+func handle(req *Req, data string, state *match_state.State) (any, error) {
+	handler, ok := Handlers[req.Command]
+	if !ok {
+		return nil, fmt.Errorf("unknown command: %s", req.Command)
+	}
+
+	resp, err := handler(req.Data, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if recorded[req.Command] {
+		state.Replay.RecordSignal(state.CurrentTick, data)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,32 @@
+package match_signal
+
+import (
+	"fmt"
+
+	"github.com/relby/achikaps/match_state"
+)
+
+type pauseResp struct {
+	Tick int64
+}
+
+// PauseHandler stops executing ticks, messages of players are still handled
+func PauseHandler(data []byte, state *match_state.State) (any, error) {
+	if err := state.Pause(""); err != nil {
+		return nil, fmt.Errorf("can't pause match: %w", err)
+	}
+
+	return &pauseResp{state.CurrentTick}, nil
+}
+
+type resumeResp struct {
+	Tick int64
+}
+
+func ResumeHandler(data []byte, state *match_state.State) (any, error) {
+	if err := state.Resume(""); err != nil {
+		return nil, fmt.Errorf("can't resume match: %w", err)
+	}
+
+	return &resumeResp{state.CurrentTick}, nil
+}
@@ -0,0 +1,30 @@
+package match_signal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/relby/achikaps/match_state"
+)
+
+type setGameSpeedReq struct {
+	GameSpeed float64
+}
+
+type setGameSpeedResp struct {
+	GameSpeed float64
+}
+
+// SetGameSpeedHandler changes how fast the game time passes
+func SetGameSpeedHandler(data []byte, state *match_state.State) (any, error) {
+	var req setGameSpeedReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	if err := state.SetGameSpeed(req.GameSpeed); err != nil {
+		return nil, fmt.Errorf("can't set game speed: %w", err)
+	}
+
+	return &setGameSpeedResp{state.GameSpeed}, nil
+}
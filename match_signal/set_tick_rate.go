@@ -0,0 +1,30 @@
+package match_signal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/relby/achikaps/match_state"
+)
+
+type setTickRateReq struct {
+	TickRate int
+}
+
+type setTickRateResp struct {
+	TickRate int
+}
+
+// SetTickRateHandler changes the number of ticks executed per second
+func SetTickRateHandler(data []byte, state *match_state.State) (any, error) {
+	var req setTickRateReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("can't unmarshal data: %w", err)
+	}
+
+	if err := state.SetTickRate(req.TickRate); err != nil {
+		return nil, fmt.Errorf("can't set tick rate: %w", err)
+	}
+
+	return &setTickRateResp{state.TickRate}, nil
+}
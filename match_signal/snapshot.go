@@ -0,0 +1,21 @@
+package match_signal
+
+import (
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/opcode"
+)
+
+type snapshotResp struct {
+	Tick int64
+	Paused bool
+	State *opcode.InitialStateResp
+}
+
+// SnapshotHandler returns the state as it's sent to the clients
+func SnapshotHandler(data []byte, state *match_state.State) (any, error) {
+	return &snapshotResp{
+		state.CurrentTick,
+		state.Paused,
+		state.Snapshot(),
+	}, nil
+}
@@ -0,0 +1,41 @@
+package match_signal
+
+import (
+	"github.com/relby/achikaps/match_state"
+)
+
+type playerStats struct {
+	SessionID string
+	IsConnected bool
+	Stats *match_state.PlayerStats
+}
+
+type statsResp struct {
+	Tick int64
+	TickRate int
+	GameSpeed float64
+	Players []*playerStats
+	Spectators int
+}
+
+// StatsHandler returns a short summary of the match
+func StatsHandler(data []byte, state *match_state.State) (any, error) {
+	resp := &statsResp{
+		Tick: state.CurrentTick,
+		TickRate: state.TickRate,
+		GameSpeed: state.GameSpeed,
+		Players: make([]*playerStats, 0, len(state.SessionIDs)),
+		Spectators: len(state.Spectators),
+	}
+
+	for _, sessionID := range state.SessionIDs {
+		_, ok := state.Presences[sessionID]
+		resp.Players = append(resp.Players, &playerStats{
+			SessionID: sessionID,
+			IsConnected: ok,
+			Stats: state.Stats(sessionID),
+		})
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,176 @@
+package match_state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/graph"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+// Tick rate can be changed by admins in this range
+const (
+	MinTickRate = 1
+	MaxTickRate = config.TickRate * 10
+)
+
+// GrantMaterials creates materials on the built node of the player
+func (s *State) GrantMaterials(sessionID string, nodeID model.ID, typ model.MaterialType, count int) ([]*model.Material, error) {
+	if !s.IsPlayer(sessionID) {
+		return nil, fmt.Errorf("player %s not found", sessionID)
+	}
+
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+
+	playerMaterials, ok := s.Materials[sessionID]
+	assert.True(ok)
+
+	n, err := playerGraph.Node(nodeID)
+	if errors.Is(err, graph.ErrVertexNotFound) {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	assert.NoError(err)
+
+	if !n.IsBuilt() {
+		return nil, fmt.Errorf("node with id %d is not built", nodeID)
+	}
+
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid count: %d", count)
+	}
+
+	out := make([]*model.Material, 0, count)
+	for range count {
+		materialID, ok := s.NextMaterialIDs[sessionID]
+		assert.True(ok)
+
+		m := model.NewMaterial(materialID, sessionID, typ, n, false)
+
+		playerMaterials[materialID] = m
+		s.NextMaterialIDs[sessionID] += 1
+
+		s.RespsWithOpcode[sessionID] = append(
+			s.RespsWithOpcode[sessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewMaterialCreatedResp(m),
+				opcode.MaterialCreated,
+			),
+		)
+
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
+// GrantUnits creates units on the built node of the player
+func (s *State) GrantUnits(sessionID string, nodeID model.ID, typ model.UnitType, count int) ([]*model.Unit, error) {
+	if !s.IsPlayer(sessionID) {
+		return nil, fmt.Errorf("player %s not found", sessionID)
+	}
+
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+
+	n, err := playerGraph.Node(nodeID)
+	if errors.Is(err, graph.ErrVertexNotFound) {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	assert.NoError(err)
+
+	if !n.IsBuilt() {
+		return nil, fmt.Errorf("node with id %d is not built", nodeID)
+	}
+
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid count: %d", count)
+	}
+
+	out := make([]*model.Unit, 0, count)
+	for range count {
+		unitID, ok := s.NextUnitIDs[sessionID]
+		assert.True(ok)
+
+		u := model.NewUnit(unitID, sessionID, typ, n)
+
+		playerUnits[unitID] = u
+		s.NextUnitIDs[sessionID] += 1
+
+		s.RespsWithOpcode[sessionID] = append(
+			s.RespsWithOpcode[sessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewUnitCreatedResp(u),
+				opcode.UnitCreated,
+			),
+		)
+
+		out = append(out, u)
+	}
+
+	return out, nil
+}
+
+// SetTickRate changes the number of ticks executed per second
+func (s *State) SetTickRate(tickRate int) error {
+	if tickRate < MinTickRate || tickRate > MaxTickRate {
+		return fmt.Errorf("tick rate should be in range from %d to %d", MinTickRate, MaxTickRate)
+	}
+
+	s.TickRate = tickRate
+
+	return nil
+}
+
+// SetGameSpeed changes how fast the game time passes
+func (s *State) SetGameSpeed(gameSpeed float64) error {
+	if gameSpeed < config.MinGameSpeed || gameSpeed > config.MaxGameSpeed {
+		return fmt.Errorf("game speed should be in range from %v to %v", config.MinGameSpeed, config.MaxGameSpeed)
+	}
+
+	s.GameSpeed = gameSpeed
+
+	return nil
+}
+
+// TickMs returns the game time in milliseconds that passes in one tick
+func (s *State) TickMs() float64 {
+	return 1000.0 / float64(s.TickRate) * s.GameSpeed
+}
+
+// TicksDue returns the number of ticks to execute in the current match loop,
+// match loop is called config.TickRate times per second
+func (s *State) TicksDue() int {
+	s.tickDebt += s.TickRate
+
+	n := s.tickDebt / config.TickRate
+	s.tickDebt %= config.TickRate
+
+	return n
+}
+
+// Inject queues the message as if it was sent by the player
+func (s *State) Inject(sessionID string, opCode opcode.OpCode, data []byte) error {
+	if !s.IsPlayer(sessionID) {
+		return fmt.Errorf("player %s not found", sessionID)
+	}
+
+	s.Injected = append(s.Injected, opcode.NewMessage(sessionID, opCode, data))
+
+	return nil
+}
+
+// TakeInjected returns queued messages and clears the queue
+func (s *State) TakeInjected() []runtime.MatchData {
+	out := s.Injected
+	s.Injected = make([]runtime.MatchData, 0)
+
+	return out
+}
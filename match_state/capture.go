@@ -0,0 +1,171 @@
+package match_state
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+// ClaimNode connects the neutral node to the node of the player,
+// the node is captured when the player holds the claim with units
+func (s *State) ClaimNode(sessionID string, fromID, nodeID model.ID) (*model.Capture, error) {
+	fromNode, err := s.playerNode(sessionID, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fromNode.IsBuilt() {
+		return nil, fmt.Errorf("node with id %d is not built", fromID)
+	}
+
+	n, ok := s.NeutralNodes[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("neutral node with id %d not found", nodeID)
+	}
+
+	if _, ok := s.Captures[nodeID][sessionID]; ok {
+		return nil, fmt.Errorf("neutral node with id %d is already claimed", nodeID)
+	}
+
+	if err := s.checkEdge(fromNode, n); err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.Captures[nodeID]; !ok {
+		s.Captures[nodeID] = make(map[string]*model.Capture)
+	}
+
+	c := model.NewCapture(sessionID, nodeID, fromID)
+	s.Captures[nodeID][sessionID] = c
+
+	return c, nil
+}
+
+// CancelClaim removes the claim of the player on the neutral node, its progress is lost
+func (s *State) CancelClaim(sessionID string, nodeID model.ID) (*model.Capture, error) {
+	c, ok := s.Captures[nodeID][sessionID]
+	if !ok {
+		return nil, fmt.Errorf("neutral node with id %d is not claimed", nodeID)
+	}
+
+	s.removeClaim(c)
+
+	return c, nil
+}
+
+func (s *State) removeClaim(c *model.Capture) {
+	delete(s.Captures[c.NodeID], c.SessionID)
+	if len(s.Captures[c.NodeID]) == 0 {
+		delete(s.Captures, c.NodeID)
+	}
+}
+
+// holdingUnits returns the number of units that stand next to the neutral node,
+// on nodes of the player that are close enough to connect to it
+func (s *State) holdingUnits(c *model.Capture) int {
+	neutralNode, ok := s.NeutralNodes[c.NodeID]
+	assert.True(ok)
+
+	count := 0
+	for _, u := range s.Units[c.SessionID] {
+		if u.Node() != nil && u.Node().DistanceTo(neutralNode) <= config.MaxNodeDistance {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// advanceCaptures moves the claim with the most holding units forward,
+// other claims start over. Nothing moves if there is a tie
+func (s *State) advanceCaptures() {
+	for _, nodeID := range slices.Sorted(maps.Keys(s.Captures)) {
+		claims := s.Captures[nodeID]
+
+		var leader *model.Capture
+		leaderUnits, tie := 0, false
+		for _, sessionID := range s.SessionIDs {
+			c, ok := claims[sessionID]
+			if !ok {
+				continue
+			}
+
+			units := s.holdingUnits(c)
+			if units < config.CaptureUnits {
+				continue
+			}
+
+			switch {
+			case units > leaderUnits:
+				leader, leaderUnits, tie = c, units, false
+			case units == leaderUnits:
+				tie = true
+			}
+		}
+
+		for _, c := range claims {
+			if c != leader || tie {
+				c.Progress = 0
+			}
+		}
+
+		if leader == nil || tie {
+			continue
+		}
+
+		leader.Progress += s.TickMs() / config.CaptureTimeMs
+		if leader.Progress >= 1.0 {
+			s.capture(leader)
+		}
+	}
+}
+
+// capture moves the neutral node to the graph of the player
+func (s *State) capture(c *model.Capture) {
+	neutralNode, ok := s.NeutralNodes[c.NodeID]
+	assert.True(ok)
+
+	playerGraph, ok := s.Graphs[c.SessionID]
+	assert.True(ok)
+
+	fromNode, err := playerGraph.Node(c.FromNodeID)
+	assert.NoError(err)
+
+	// Edge could be blocked by nodes that were built while the node was being captured
+	if err := s.checkEdge(fromNode, neutralNode); err != nil {
+		s.removeClaim(c)
+		return
+	}
+
+	nodeID, ok := s.NextNodeIDs[c.SessionID]
+	assert.True(ok)
+
+	n := model.NewNode(nodeID, c.SessionID, s.Factions[c.SessionID], neutralNode.Name(), neutralNode.Position())
+	n.BuildFully()
+
+	err = playerGraph.AddNodeFrom(fromNode, n)
+	assert.NoError(err)
+
+	s.NextNodeIDs[c.SessionID] += 1
+
+	delete(s.NeutralNodes, c.NodeID)
+	delete(s.Captures, c.NodeID)
+
+	// Enemies only see that the neutral node is gone
+	s.Events = append(
+		s.Events,
+		&Event{
+			opcode.NewRespWithOpCode(
+				opcode.NewNodeCapturedResp(c.SessionID, c.NodeID, n, c.FromNodeID),
+				opcode.NodeCaptured,
+			),
+			c.SessionID,
+			opcode.NewNodeCapturedResp("", c.NodeID, nil, 0),
+		},
+	)
+}
@@ -0,0 +1,178 @@
+package match_state
+
+import (
+	"math"
+	"testing"
+
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/rules"
+	"github.com/relby/achikaps/vec2"
+)
+
+// newCaptureState returns the state with the only neutral node
+// that can be claimed from the root node of the first player
+func newCaptureState(t *testing.T, units uint) (*State, *model.Node) {
+	t.Helper()
+
+	r := rules.Default()
+	r.StartTransitNodes = 0
+	r.Units = map[model.UnitType]uint{model.IdleUnitType: units}
+	s := newTestState(t, r, "a", "b")
+
+	root := rootNode(t, s, "a")
+	s.NeutralNodes = make(map[model.ID]*model.Node)
+	for i := range 16 {
+		angle := float64(i) * math.Pi / 8
+		pos := root.Position().Add(vec2.New(math.Cos(angle), math.Sin(angle)).MulScalar(config.MaxNodeDistance - config.NodeRadius))
+
+		n := model.NewNode(1, "", 0, root.Name(), pos)
+		n.BuildFully()
+		s.NeutralNodes[n.ID()] = n
+		if s.checkEdge(root, n) == nil && !s.Map.NodeIntersectsAny(n) {
+			return s, n
+		}
+	}
+
+	t.Fatal("can't place neutral node next to the root node")
+	return nil, nil
+}
+
+// holdWith gives the player units that stand on a node next to the neutral node
+func holdWith(s *State, sessionID string, neutralNode *model.Node, units int) {
+	n := model.NewNode(1, sessionID, model.DefaultFaction, neutralNode.Name(), neutralNode.Position().AddScalars(config.MinNodeDistance, 0))
+
+	s.Units[sessionID] = make(map[model.ID]*model.Unit, units)
+	for i := range units {
+		id := model.ID(i + 1)
+		s.Units[sessionID][id] = model.NewUnit(id, sessionID, model.IdleUnitType, n)
+	}
+
+	if _, ok := s.Captures[neutralNode.ID()]; !ok {
+		s.Captures[neutralNode.ID()] = make(map[string]*model.Capture)
+	}
+	s.Captures[neutralNode.ID()][sessionID] = model.NewCapture(sessionID, neutralNode.ID(), 1)
+}
+
+func TestClaimNode(t *testing.T) {
+	s, n := newCaptureState(t, uint(config.CaptureUnits))
+
+	if _, err := s.ClaimNode("a", 1, n.ID()); err != nil {
+		t.Fatalf("can't claim node: %v", err)
+	}
+	if _, err := s.ClaimNode("a", 1, n.ID()); err == nil {
+		t.Error("claimed node twice")
+	}
+	if _, err := s.ClaimNode("a", 1, n.ID() + 1); err == nil {
+		t.Error("claimed unknown node")
+	}
+	if _, err := s.ClaimNode("b", 1, n.ID()); err == nil {
+		t.Error("claimed node that is too far")
+	}
+}
+
+func TestCaptureNode(t *testing.T) {
+	s, n := newCaptureState(t, uint(config.CaptureUnits))
+
+	if _, err := s.ClaimNode("a", 1, n.ID()); err != nil {
+		t.Fatalf("can't claim node: %v", err)
+	}
+
+	// Units that are busy still hold the claim
+	for _, u := range s.Units["a"] {
+		u.Actions().PushBack(model.NewBuildingUnitAction())
+	}
+
+	ticks := int(math.Ceil(config.CaptureTimeMs / s.TickMs()))
+	for range ticks - 1 {
+		s.advanceCaptures()
+	}
+	if _, ok := s.NeutralNodes[n.ID()]; !ok {
+		t.Fatal("node is captured too early")
+	}
+
+	s.advanceCaptures()
+	if _, ok := s.NeutralNodes[n.ID()]; ok {
+		t.Fatal("node is not captured")
+	}
+	if len(s.Captures) != 0 {
+		t.Errorf("captures = %d, want 0", len(s.Captures))
+	}
+
+	captured := false
+	for _, playerNode := range s.Graphs["a"].Nodes() {
+		if playerNode.Position() == n.Position() {
+			captured = playerNode.IsBuilt()
+		}
+	}
+	if !captured {
+		t.Error("captured node is not in the graph of the player")
+	}
+	if len(s.Events) != 1 {
+		t.Errorf("events = %d, want 1", len(s.Events))
+	}
+}
+
+func TestAdvanceCaptures(t *testing.T) {
+	tests := []struct {
+		name string
+		units int
+		enemyUnits int
+		wantProgress bool
+	}{
+		{"not enough units", config.CaptureUnits - 1, 0, false},
+		{"enough units", config.CaptureUnits, 0, true},
+		{"more units than the enemy", config.CaptureUnits + 1, config.CaptureUnits, true},
+		{"tie", config.CaptureUnits, config.CaptureUnits, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, n := newCaptureState(t, uint(tt.units))
+			if _, err := s.ClaimNode("a", 1, n.ID()); err != nil {
+				t.Fatalf("can't claim node: %v", err)
+			}
+			if tt.enemyUnits > 0 {
+				holdWith(s, "b", n, tt.enemyUnits)
+			}
+
+			s.advanceCaptures()
+
+			if got := s.Captures[n.ID()]["a"].Progress > 0; got != tt.wantProgress {
+				t.Errorf("claim progressed = %v, want %v", got, tt.wantProgress)
+			}
+			if c, ok := s.Captures[n.ID()]["b"]; ok && c.Progress > 0 {
+				t.Error("claim of the enemy progressed")
+			}
+		})
+	}
+}
+
+func TestCancelClaim(t *testing.T) {
+	s, n := newCaptureState(t, uint(config.CaptureUnits))
+
+	if _, err := s.CancelClaim("a", n.ID()); err == nil {
+		t.Error("canceled claim that doesn't exist")
+	}
+
+	if _, err := s.ClaimNode("a", 1, n.ID()); err != nil {
+		t.Fatalf("can't claim node: %v", err)
+	}
+	s.advanceCaptures()
+
+	if _, err := s.CancelClaim("a", n.ID()); err != nil {
+		t.Fatalf("can't cancel claim: %v", err)
+	}
+	if len(s.Captures) != 0 {
+		t.Errorf("captures = %d, want 0", len(s.Captures))
+	}
+
+	// Progress is lost and the node can be claimed again
+	c, err := s.ClaimNode("a", 1, n.ID())
+	if err != nil {
+		t.Fatalf("can't claim node again: %v", err)
+	}
+	if c.Progress != 0 {
+		t.Errorf("progress = %v, want 0", c.Progress)
+	}
+}
@@ -4,20 +4,30 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"math/rand"
+	"maps"
+	"math/rand/v2"
+	"slices"
 
 	"github.com/heroiclabs/nakama-common/runtime"
 	"github.com/relby/achikaps/assert"
 	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/game_map"
 	"github.com/relby/achikaps/graph"
 	"github.com/relby/achikaps/model"
 	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/replay"
+	"github.com/relby/achikaps/rules"
+	"github.com/relby/achikaps/tech_tree"
 	"github.com/relby/achikaps/vec2"
 	"github.com/relby/achikaps/win_condition"
 )
 
 type State struct {
+	// Players in the order they were matched, everything that depends on order iterates over it
+	SessionIDs []string
 	Presences   map[string]runtime.Presence
+	// Spectators receive all updates, but they are not players
+	Spectators map[string]runtime.Presence
 
 	Graphs map[string]*graph.Graph
 	NextNodeIDs map[string]model.ID
@@ -30,7 +40,337 @@ type State struct {
 	
 	WinCondition *win_condition.WinCondition
 	
+	// Obstacles, deposits and neutral sites, it's generated from the seed
+	Map *game_map.Map
+	// Nodes on the sites of the map that are not captured yet
+	NeutralNodes map[model.ID]*model.Node
+	// Claims of players on neutral nodes by node IDs and session IDs
+	Captures map[model.ID]map[string]*model.Capture
+	
+	TechTree *tech_tree.TechTree
+	// Researched techs of players
+	Techs map[string]map[model.ID]bool
+	// Players research one tech at a time
+	Researches map[string]*model.Research
+	
+	// Factions of players, they change recipes, costs and units
+	Factions map[string]model.Faction
+	
+	// Teams of players, materials of all team members count toward the win condition
+	Teams map[string]uint
+	// Players see only updates of their team
+	FogOfWar bool
+	
+	// Offers that are not accepted or rejected yet
+	Trades map[model.ID]*model.Trade
+	NextTradeID model.ID
+	
+	// Progress to the next meal of all units
+	UpkeepProgress float64
+	// Populations that are sent to players, they are updated at the end of the tick
+	Populations map[string]*model.Population
+	
+	// Target share of every unit type in percents
+	RoleQuotas map[string]map[model.UnitType]uint
+	
 	RespsWithOpcode map[string][]*opcode.RespWithOpCode
+
+	// Same seed and same client messages result in the same match
+	Seed uint64
+	Rand *rand.Rand
+	
+	// Number of executed ticks
+	CurrentTick int64
+	Replay *replay.Replay
+
+	// Number of ticks per second, admins can change it while the match is running.
+	// Durations don't depend on it, it only changes how smooth the simulation is
+	TickRate int
+	// Game time passes faster than real time by this factor
+	GameSpeed float64
+	tickDebt int
+	
+	// Match is ended by an admin
+	Ended bool
+	// Ticks are not executed while the match is paused
+	Paused bool
+	// Player that paused the match, empty if it's paused by an admin
+	PausedBy string
+	// Number of match loops the match is paused for
+	PausedLoops int
+	PausesLeft map[string]uint
+	// Pause of a player is resumed automatically after this time
+	MaxPauseMs uint
+	// Match wide events, they are broadcasted to everyone
+	Events []*Event
+	// Messages sent on behalf of players, they are handled in the next match loop
+	Injected []runtime.MatchData
+}
+
+// Event is broadcasted to everyone, under fog of war enemies of the player get the hidden resp instead
+type Event struct {
+	*opcode.RespWithOpCode
+	// Player the event is about, empty if it's the same for everyone
+	SessionID string
+	Hidden any
+}
+
+// sortedByID returns values ordered by their IDs, so that the simulation doesn't depend on map iteration order
+func sortedByID[V any](m map[model.ID]V) []V {
+	out := make([]V, 0, len(m))
+	for _, id := range slices.Sorted(maps.Keys(m)) {
+		out = append(out, m[id])
+	}
+
+	return out
+}
+
+// Start transit node is placed at a random position around the root, the match fails if it doesn't fit after this many attempts
+const startTransitAttempts = 100
+
+// New creates the starting state of the match, players without a faction play the default one
+func New(sessionIDs []string, factions map[string]model.Faction, r *rules.Rules, seed uint64) (*State, error) {
+	s := &State{
+		SessionIDs: slices.Clone(sessionIDs),
+		Presences:   make(map[string]runtime.Presence, len(sessionIDs)),
+		Spectators: make(map[string]runtime.Presence),
+
+		Graphs: make(map[string]*graph.Graph, len(sessionIDs)),
+		NextNodeIDs: make(map[string]model.ID, len(sessionIDs)),
+
+		Units: make(map[string]map[model.ID]*model.Unit, len(sessionIDs)),
+		NextUnitIDs: make(map[string]model.ID, len(sessionIDs)),
+
+		Materials: make(map[string]map[model.ID]*model.Material, len(sessionIDs)),
+		NextMaterialIDs: make(map[string]model.ID, len(sessionIDs)),
+		
+		WinCondition: r.WinCondition,
+
+		Teams: make(map[string]uint, len(sessionIDs)),
+		Factions: make(map[string]model.Faction, len(sessionIDs)),
+		FogOfWar: r.FogOfWar,
+
+		TechTree: tech_tree.Default(),
+		Techs: make(map[string]map[model.ID]bool, len(sessionIDs)),
+		Researches: make(map[string]*model.Research, len(sessionIDs)),
+
+		Trades: make(map[model.ID]*model.Trade),
+		NextTradeID: model.ID(1),
+
+		UpkeepProgress: 0,
+		Populations: make(map[string]*model.Population, len(sessionIDs)),
+
+		RoleQuotas: make(map[string]map[model.UnitType]uint, len(sessionIDs)),
+
+		RespsWithOpcode: make(map[string][]*opcode.RespWithOpCode, len(sessionIDs)),
+
+		Seed: seed,
+		Rand: rand.New(rand.NewPCG(seed, seed)),
+
+		CurrentTick: 0,
+		Replay: replay.New(seed, r, sessionIDs, factions),
+
+		TickRate: config.TickRate,
+		GameSpeed: r.GameSpeed,
+		tickDebt: 0,
+		
+		Ended: false,
+		Paused: false,
+		PausedBy: "",
+		PausedLoops: 0,
+		PausesLeft: make(map[string]uint, len(sessionIDs)),
+		MaxPauseMs: r.MaxPauseMs,
+		Events: make([]*Event, 0),
+		Injected: make([]runtime.MatchData, 0),
+	}
+	
+	s.Map = game_map.Generate(len(sessionIDs), r, s.Rand)
+	s.NeutralNodes = make(map[model.ID]*model.Node, len(s.Map.Sites))
+	s.Captures = make(map[model.ID]map[string]*model.Capture)
+	for i, site := range s.Map.Sites {
+		n := model.NewNode(model.ID(i + 1), "", 0, site.Type.NodeName(), site.Position)
+		n.BuildFully()
+
+		s.NeutralNodes[n.ID()] = n
+	}
+	
+	for i, sessionID := range sessionIDs {
+		s.PausesLeft[sessionID] = r.Pauses
+		s.Techs[sessionID] = make(map[model.ID]bool)
+		s.Factions[sessionID] = model.DefaultFaction
+		if f, ok := factions[sessionID]; ok {
+			s.Factions[sessionID] = f
+		}
+		// Team members are next to each other on the map
+		s.Teams[sessionID] = uint(i) / r.TeamSize + 1
+
+		root := model.NewNode(
+			model.ID(1),
+			sessionID,
+			s.Factions[sessionID],
+			model.SandTransitNodeName,
+			s.Map.Starts[i],
+		)
+		root.BuildFully()
+
+		g := graph.New(root)
+
+		s.Graphs[sessionID] = g
+
+		nodeID := model.ID(2)
+		for range r.StartTransitNodes {
+			var n *model.Node
+			placed := false
+			for range startTransitAttempts {
+				angle := s.Rand.Float64() * 2 * math.Pi
+				radius := config.MinNodeDistance + s.Rand.Float64() * (config.MaxNodeDistance - config.MinNodeDistance)
+				pos := vec2.New(
+					root.Position().X + radius*math.Cos(angle),
+					root.Position().Y + radius*math.Sin(angle),
+				)
+				
+				n = model.NewNode(
+					nodeID,
+					sessionID,
+					s.Factions[sessionID],
+					model.SandTransitNodeName,
+					pos,
+				)
+				n.BuildFully()
+				
+				if !g.NodeIntersectsAny(n) && !s.Map.NodeIntersectsAny(n) && !s.neutralNodesIntersect(n) {
+					placed = true
+					break
+				}
+			}
+			if !placed {
+				return nil, fmt.Errorf("can't place start transit node %d of player %s", nodeID, sessionID)
+			}
+			
+			err := g.AddNodeFrom(root, n)
+			assert.NoError(err)
+
+			nodeID += 1
+		}
+
+		s.NextNodeIDs[sessionID] = nodeID
+		
+		s.Units[sessionID] = make(map[model.ID]*model.Unit)
+		c := model.ID(1)
+		for _, t := range rules.UnitTypes {
+			for range r.Units[t] {
+				s.Units[sessionID][c] = model.NewUnit(c, sessionID, t, root)
+				c += 1
+			}
+		}
+		s.NextUnitIDs[sessionID] = c
+
+		s.Materials[sessionID] = make(map[model.ID]*model.Material)
+		c = model.ID(1)
+		for _, t := range rules.MaterialTypes {
+			for range r.Materials[t] {
+				s.Materials[sessionID][c] = model.NewMaterial(c, sessionID, t, root, false)
+				c += 1
+			}
+		}
+		s.NextMaterialIDs[sessionID] = c
+
+		s.Populations[sessionID] = model.NewPopulation(len(s.Units[sessionID]), s.MaxPopulation(sessionID))
+	}
+	
+	return s, nil
+}
+
+func (s *State) IsPlayer(sessionID string) bool {
+	return slices.Contains(s.SessionIDs, sessionID)
+}
+
+// Snapshot returns the whole state as it's sent to the clients
+func (s *State) Snapshot() *opcode.InitialStateResp {
+	return s.snapshot(func(string) bool { return true })
+}
+
+// SnapshotFor returns the state as it's seen by the player, under fog of war only the team of the player is visible
+func (s *State) SnapshotFor(sessionID string) *opcode.InitialStateResp {
+	if !s.FogOfWar {
+		return s.Snapshot()
+	}
+
+	return s.snapshot(func(id string) bool { return s.IsAlly(sessionID, id) })
+}
+
+// visibleOf returns values of players that are visible
+func visibleOf[V any](m map[string]V, visible func(string) bool) map[string]V {
+	out := make(map[string]V, len(m))
+	for sessionID, v := range m {
+		if visible(sessionID) {
+			out[sessionID] = v
+		}
+	}
+
+	return out
+}
+
+func (s *State) snapshot(visible func(string) bool) *opcode.InitialStateResp {
+	resp := &opcode.InitialStateResp{}
+
+	resp.Nodes = make(map[string]map[model.ID]*model.Node, len(s.Graphs))
+	resp.Connections = make(map[string]map[model.ID][]model.ID, len(s.Graphs))
+	for uID, g := range s.Graphs {
+		if !visible(uID) {
+			continue
+		}
+
+		resp.Nodes[uID] = g.Nodes()
+
+		am := g.AdjacencyMap()
+		resp.Connections[uID] = make(map[model.ID][]model.ID, len(am))
+		for k, v := range am {
+			resp.Connections[uID][k] = slices.Sorted(maps.Keys(v))
+		}
+	}
+
+	resp.Captures = make(map[model.ID]map[string]*model.Capture, len(s.Captures))
+	for nodeID, captures := range s.Captures {
+		if visibleCaptures := visibleOf(captures, visible); len(visibleCaptures) > 0 {
+			resp.Captures[nodeID] = visibleCaptures
+		}
+	}
+
+	resp.Units = visibleOf(s.Units, visible)
+	resp.Materials = visibleOf(s.Materials, visible)
+	resp.WinCondition = s.WinCondition
+	resp.Teams = s.Teams
+	resp.Factions = s.Factions
+	resp.Map = s.Map
+	resp.NeutralNodes = s.NeutralNodes
+	resp.TechTree = s.TechTree
+	resp.Techs = visibleOf(s.Techs, visible)
+	resp.Researches = visibleOf(s.Researches, visible)
+	resp.UpkeepProgress = s.UpkeepProgress
+	resp.Populations = visibleOf(s.Populations, visible)
+	
+	return resp
+}
+
+// Winner returns the player whose team has collected enough materials to win
+func (s *State) Winner() (string, bool) {
+	counts := make(map[uint]int, len(s.SessionIDs))
+	for _, sessionID := range s.SessionIDs {
+		for _, m := range s.Materials[sessionID] {
+			if m.Type() == s.WinCondition.MaterialType {
+				counts[s.Teams[sessionID]] += 1
+			}
+		}
+	}
+	
+	for _, sessionID := range s.SessionIDs {
+		if counts[s.Teams[sessionID]] >= s.WinCondition.Count {
+			return sessionID, true
+		}
+	}
+	
+	return "", false
 }
 
 func (s *State) BuildNode(sessionID string, fromID model.ID, name model.NodeName, pos vec2.Vec2) (*model.Node, error) {
@@ -43,36 +383,104 @@ func (s *State) BuildNode(sessionID string, fromID model.ID, name model.NodeName
 	}
 	assert.NoError(err)
 	
+	if !s.Factions[sessionID].CanBuild(name) {
+		return nil, fmt.Errorf("node is unique to another faction")
+	}
+
+	if !s.IsUnlocked(sessionID, name) {
+		return nil, fmt.Errorf("node name %d: %w", name, ErrNodeLocked)
+	}
+
 	toNodeID, ok := s.NextNodeIDs[sessionID]
 	assert.True(ok)
 
-	toNode := model.NewNode(toNodeID, sessionID, name, pos)
+	toNode := model.NewNode(toNodeID, sessionID, s.Factions[sessionID], name, pos)
 	
-	if fromNode.DistanceTo(toNode) < config.MinNodeDistance {
-		return nil, fmt.Errorf("new node is close")
+	deposit, onDeposit := s.Map.DepositAt(pos)
+	if typ, ok := model.RequiredDeposit(name); ok && (!onDeposit || deposit.Type != typ) {
+		return nil, fmt.Errorf("node can be built only on the deposit of type %d", typ)
+	}
+	if onDeposit {
+		toNode.SetDeposit(deposit.Type)
 	}
 	
-	if fromNode.DistanceTo(toNode) > config.MaxNodeDistance {
-		return nil, fmt.Errorf("new node is too far")
+	if err := s.CheckPlacement(fromNode, toNode); err != nil {
+		return nil, err
+	}
+
+	if err := playerGraph.AddNodeFrom(fromNode, toNode); err != nil {
+		return nil, fmt.Errorf("can't add node: %w", err)
+	}
+
+	s.NextNodeIDs[sessionID] += 1
+
+	return toNode, nil
+}
+
+// CheckPlacement checks if the new node can be built from the node
+func (s *State) CheckPlacement(fromNode, toNode *model.Node) error {
+	if err := s.checkEdge(fromNode, toNode); err != nil {
+		return err
+	}
+
+	if s.Map.NodeIntersectsAny(toNode) {
+		return fmt.Errorf("new node intersects the map")
+	}
+
+	if s.neutralNodesIntersect(toNode) {
+		return fmt.Errorf("new node intersects a neutral node")
 	}
 
 	for _, g := range s.Graphs {
 		if g.NodeIntersectsAny(toNode) {
-			return nil, fmt.Errorf("new node intersects the graph")
+			return fmt.Errorf("new node intersects the graph")
 		}
-		
-		if g.EdgeIntersectsAny(fromNode, toNode) {
-			return nil, fmt.Errorf("new edge intersects the graph")
+	}
+
+	return nil
+}
+
+// checkEdge checks if the edge from the node to another node can be built
+func (s *State) checkEdge(fromNode, toNode *model.Node) error {
+	if fromNode.DistanceTo(toNode) < config.MinNodeDistance {
+		return fmt.Errorf("new node is close")
+	}
+	
+	if fromNode.DistanceTo(toNode) > config.MaxNodeDistance {
+		return fmt.Errorf("new node is too far")
+	}
+
+	if s.Map.EdgeIntersectsAny(fromNode, toNode) {
+		return fmt.Errorf("new edge intersects the map")
+	}
+
+	for _, n := range s.NeutralNodes {
+		if n == toNode {
+			continue
+		}
+
+		if vec2.DistanceToSegment(n.Position(), fromNode.Position(), toNode.Position()) < n.Radius() {
+			return fmt.Errorf("new edge intersects a neutral node")
 		}
 	}
 
-	if err := playerGraph.AddNodeFrom(fromNode, toNode); err != nil {
-		return nil, fmt.Errorf("can't add node: %w", err)
+	for _, g := range s.Graphs {
+		if g.EdgeIntersectsAny(fromNode, toNode) {
+			return fmt.Errorf("new edge intersects the graph")
+		}
 	}
 
-	s.NextNodeIDs[sessionID] += 1
+	return nil
+}
 
-	return toNode, nil
+func (s *State) neutralNodesIntersect(n *model.Node) bool {
+	for _, neutralNode := range s.NeutralNodes {
+		if neutralNode.Intersects(n) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (s *State) ChangeUnitType(sessionID string, id model.ID, typ model.UnitType) (*model.Unit, error) {
@@ -89,17 +497,227 @@ func (s *State) ChangeUnitType(sessionID string, id model.ID, typ model.UnitType
 	return u, nil
 }
 
+// ChangeUnitTypes changes the type of all units at once, nothing is changed if any of the units is not found
+func (s *State) ChangeUnitTypes(sessionID string, ids []model.ID, typ model.UnitType) ([]*model.Unit, error) {
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+
+	units := make([]*model.Unit, 0, len(ids))
+	for _, id := range ids {
+		u, exists := playerUnits[id]
+		if !exists {
+			return nil, fmt.Errorf("unit with id %d not found", id)
+		}
+
+		units = append(units, u)
+	}
+
+	for _, u := range units {
+		u.SetType(typ)
+	}
+
+	return units, nil
+}
+
+// SelectUnits returns up to count units of the type that are standing at the node, lowest IDs first
+func (s *State) SelectUnits(sessionID string, nodeID model.ID, typ model.UnitType, count int) ([]*model.Unit, error) {
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+	
+	n, err := playerGraph.Node(nodeID)
+	if errors.Is(err, graph.ErrVertexNotFound) {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	assert.NoError(err)
+	
+	units := make([]*model.Unit, 0, len(n.Units()))
+	for _, u := range sortedByID(n.Units()) {
+		if u.Type() == typ {
+			units = append(units, u)
+		}
+	}
+	
+	if len(units) > count {
+		units = units[:count]
+	}
+	
+	return units, nil
+}
+
+func (s *State) CommandUnit(sessionID string, id model.ID, cmd *model.UnitCommand) (*model.Unit, error) {
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+	
+	u, exists := playerUnits[id]
+	if !exists {
+		return nil, fmt.Errorf("unit not found")
+	}
+	
+	switch cmd.Type {
+	case model.MoveUnitCommandType:
+		data, ok := cmd.Data.(*model.MoveUnitCommandData)
+		assert.True(ok)
+
+		if !data.Node.IsBuilt() {
+			return nil, fmt.Errorf("node is not built")
+		}
+	case model.WorkUnitCommandType:
+		data, ok := cmd.Data.(*model.WorkUnitCommandData)
+		assert.True(ok)
+
+		if u.Type() != model.ProductionUnitType {
+			return nil, fmt.Errorf("only production units can work")
+		}
+		
+		if data.Node.Type() != model.ProductionNodeType {
+			return nil, fmt.Errorf("node is not a production node")
+		}
+
+		if !data.Node.IsBuilt() {
+			return nil, fmt.Errorf("node is not built")
+		}
+	case model.HaulUnitCommandType:
+		data, ok := cmd.Data.(*model.HaulUnitCommandData)
+		assert.True(ok)
+
+		if u.Type() != model.TransportUnitType {
+			return nil, fmt.Errorf("only transport units can haul")
+		}
+		
+		if data.FromNode.ID() == data.ToNode.ID() {
+			return nil, fmt.Errorf("can't haul to the same node")
+		}
+
+		if !data.FromNode.IsBuilt() || !data.ToNode.IsBuilt() {
+			return nil, fmt.Errorf("node is not built")
+		}
+	default:
+		panic("unreachable")
+	}
+	
+	u.SetCommand(cmd)
+	
+	return u, nil
+}
+
+func (s *State) ClearUnitCommand(sessionID string, id model.ID) (*model.Unit, error) {
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+	
+	u, exists := playerUnits[id]
+	if !exists {
+		return nil, fmt.Errorf("unit not found")
+	}
+	
+	if u.Command() == nil {
+		return nil, fmt.Errorf("unit has no command")
+	}
+	
+	u.ClearCommand()
+	
+	return u, nil
+}
+
+func (s *State) SetNodePriority(sessionID string, id model.ID, p model.NodePriority) (*model.Node, error) {
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+	
+	n, err := playerGraph.Node(id)
+	if errors.Is(err, graph.ErrVertexNotFound) {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	assert.NoError(err)
+	
+	n.SetPriority(p)
+	
+	return n, nil
+}
+
+// UpgradeNode raises the level of the production node, builders build it again with the cost of the new level.
+// Inputs of the production are moved to the output, so they are not spent on the upgrade
+func (s *State) UpgradeNode(sessionID string, id model.ID) (*model.Node, error) {
+	n, err := s.playerNode(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Type() != model.ProductionNodeType {
+		return nil, fmt.Errorf("only production nodes can be upgraded")
+	}
+
+	if !n.IsBuilt() {
+		return nil, fmt.Errorf("node with id %d is not built", id)
+	}
+
+	if n.Level() >= model.MaxNodeLevel {
+		return nil, fmt.Errorf("node is already at the max level")
+	}
+
+	for _, m := range sortedByID(n.InputMaterials()) {
+		if m.IsReserved() {
+			continue
+		}
+
+		n.RemoveInputMaterial(m)
+		n.AddOutputMaterial(m)
+	}
+
+	n.Upgrade()
+
+	return n, nil
+}
+
+// SetRoleQuota sets the target share of unit types in percents, empty quota disables reconciliation
+func (s *State) SetRoleQuota(sessionID string, quota map[model.UnitType]uint) error {
+	if len(quota) == 0 {
+		delete(s.RoleQuotas, sessionID)
+		return nil
+	}
+
+	var sum uint
+	for t, p := range quota {
+		// Big values would wrap the sum around
+		if p > 100 {
+			return fmt.Errorf("quota of the unit type %d is over 100 percents: %d", t, p)
+		}
+		sum += p
+	}
+	
+	if sum != 100 {
+		return fmt.Errorf("quota should sum up to 100 percents, got %d", sum)
+	}
+	
+	s.RoleQuotas[sessionID] = quota
+	
+	return nil
+}
+
 func (s *State) Tick() {
-	for sessionID, units := range s.Units {
-		for _, u := range units {
-			if u.Actions().Len() == 0 {
+	// Units that starve to death are removed before anything else counts them in this tick
+	s.advanceUpkeep()
+
+	for _, sessionID := range s.SessionIDs {
+		if quota, ok := s.RoleQuotas[sessionID]; ok {
+			s.reconcileRoleQuota(sessionID, quota)
+		}
+	}
+
+	for _, sessionID := range s.SessionIDs {
+		for _, u := range sortedByID(s.Units[sessionID]) {
+			if u.Actions().Len() != 0 {
+				continue
+			}
+
+			if u.Command() != nil {
+				s.pollCommandActions(sessionID, u)
+			} else {
 				s.pollActions(sessionID, u)
 			}
 		}
 	}
 		
-	for sessionID, units := range s.Units {
-		for _, u := range units {
+	for _, sessionID := range s.SessionIDs {
+		for _, u := range sortedByID(s.Units[sessionID]) {
 			if u.Actions().Len() == 0 {
 				continue
 			}
@@ -123,6 +741,75 @@ func (s *State) Tick() {
 			}
 		}
 	}
+	
+	s.advanceCaptures()
+	s.advanceResearches()
+	s.updatePopulations()
+	
+	s.CurrentTick += 1
+}
+
+// reconcileRoleQuota changes the type of at most one free unit per tick towards the quota
+func (s *State) reconcileRoleQuota(sessionID string, quota map[model.UnitType]uint) {
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+	
+	counts := make(map[model.UnitType]int, len(quota))
+	for _, u := range playerUnits {
+		counts[u.Type()] += 1
+	}
+	
+	surplus := func(t model.UnitType) int {
+		return counts[t] - len(playerUnits) * int(quota[t]) / 100
+	}
+
+	// Pick the type that lacks the most units
+	var neededType model.UnitType
+	maxDeficit := 0
+	for _, t := range []model.UnitType{model.IdleUnitType, model.ProductionUnitType, model.BuilderUnitType, model.TransportUnitType} {
+		if deficit := -surplus(t); deficit > maxDeficit {
+			maxDeficit = deficit
+			neededType = t
+		}
+	}
+	
+	if neededType == 0 {
+		return
+	}
+	
+	// Only units without actions are converted so that nothing in progress is broken,
+	// idle units go first
+	var candidate *model.Unit
+	for _, u := range playerUnits {
+		if u.Actions().Len() != 0 || u.Command() != nil || surplus(u.Type()) <= 0 {
+			continue
+		}
+		
+		if candidate == nil {
+			candidate = u
+			continue
+		}
+		
+		candidateIsIdle := candidate.Type() == model.IdleUnitType
+		uIsIdle := u.Type() == model.IdleUnitType
+		if uIsIdle && !candidateIsIdle || uIsIdle == candidateIsIdle && u.ID() < candidate.ID() {
+			candidate = u
+		}
+	}
+	
+	if candidate == nil {
+		return
+	}
+	
+	candidate.SetType(neededType)
+
+	s.RespsWithOpcode[sessionID] = append(
+		s.RespsWithOpcode[sessionID],
+		opcode.NewRespWithOpCode(
+			opcode.NewUnitTypeChangedResp(candidate),
+			opcode.UnitTypeChanged,
+		),
+	)
 }
 
 // pollActions tries to add action to a unit
@@ -141,10 +828,7 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 		adjacentNodeMap, ok := am[u.Node().ID()]
 		assert.True(ok)
 
-		adjacentNodes := make([]model.ID, 0, len(adjacentNodeMap))
-		for nodeID := range adjacentNodeMap {
-			adjacentNodes = append(adjacentNodes, nodeID)
-		}
+		adjacentNodes := slices.Sorted(maps.Keys(adjacentNodeMap))
 		
 		// Filter out nodes that are not built yet
 		builtNodes := make([]*model.Node, 0, len(adjacentNodes))
@@ -161,7 +845,7 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 			return nil, false
 		}
 
-		randomNode := builtNodes[rand.Intn(len(builtNodes))]
+		randomNode := builtNodes[s.Rand.IntN(len(builtNodes))]
 
 		return randomNode, true
 	}
@@ -199,7 +883,7 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 			return
 		}
 
-		u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, u.Node(), n))
+		u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), u.Node(), n))
 	case model.ProductionUnitType:
 		finalNode := u.Node()
 		// If unit is not in the production node find the node with the least amount of units
@@ -213,7 +897,7 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 					return
 				}
 				
-				u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, u.Node(), n))
+				u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), u.Node(), n))
 				return
 			}
 			
@@ -235,56 +919,12 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 				}
 			}
 
-			ns := playerGraph.FindShortestPath(u.Node(), leastPopulatedNode)
-
-			for i := range len(ns) - 1 {
-				n1, n2 := ns[i], ns[i + 1]
-				u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, n1, n2))
-			}
+			s.pushMovingActions(playerGraph, u, u.Node(), leastPopulatedNode)
 
 			finalNode = leastPopulatedNode
 		}
 
-		data, ok := finalNode.ProductionData()
-		assert.True(ok)
-		
-		inputMaterials := make([]*model.Material, 0, len(data.InputMaterials))
-
-		enoughMaterials := false
-		if len(data.InputMaterials) >= 0 {
-			for _, m := range finalNode.InputMaterials() {
-				c, exists := data.InputMaterials[m.Type()];
-				if !exists {
-					continue
-				}
-				// Ensure that there's at least 1 input material to build a node
-				assert.NotEquals(c, 0)
-
-				c -= 1
-				if c == 0 {
-					delete(data.InputMaterials, m.Type())
-				} else {
-					data.InputMaterials[m.Type()] = c
-				}
-
-				inputMaterials = append(inputMaterials, m)
-
-				if len(data.InputMaterials) == 0 {
-					enoughMaterials = true
-					break
-				}
-			}
-		}
-		
-		if !enoughMaterials {
-			return 
-		}
-		
-		for _, m := range inputMaterials {
-			m.Reserve()
-		}
-
-		u.Actions().PushBack(model.NewProductionUnitAction(inputMaterials))
+		s.pushProductionAction(u, finalNode)
 	case model.BuilderUnitType:
 		buildingNodes := playerGraph.BuildingNodes()
 		if len(buildingNodes) == 0 {
@@ -294,13 +934,17 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 				return
 			}
 			
-			u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, u.Node(), n))
+			u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), u.Node(), n))
 			return
 		}
 		
 
 		validBuildingNodes := make([]*model.Node, 0, len(buildingNodes))
 		for _, n := range buildingNodes {
+			if n.Priority() == model.PausedNodePriority {
+				continue
+			}
+
 			data := n.BuildingData()
 			
 			// Every building should require some materials to build
@@ -340,18 +984,22 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 				return
 			}
 			
-			u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, u.Node(), n))
+			u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), u.Node(), n))
 			return
 		}
 
-		shortestPath, finalNode := findShortestPathOfMultiple(validBuildingNodes)
-
-		if u.Node().ID() != finalNode.ID() {
-			for i := range len(shortestPath) - 1 {
-				n1, n2 := shortestPath[i], shortestPath[i + 1]
-				u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, n1, n2))
-			}
+		// Only the nodes with the highest priority are considered
+		maxPriority := model.PausedNodePriority
+		for _, n := range validBuildingNodes {
+			maxPriority = max(maxPriority, n.Priority())
 		}
+		validBuildingNodes = slices.DeleteFunc(validBuildingNodes, func(n *model.Node) bool {
+			return n.Priority() != maxPriority
+		})
+
+		_, finalNode := findShortestPathOfMultiple(validBuildingNodes)
+
+		s.pushMovingActions(playerGraph, u, u.Node(), finalNode)
 		
 		u.Actions().PushBack(model.NewBuildingUnitAction())
 	case model.TransportUnitType:
@@ -361,7 +1009,20 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 			playerGraph.NodeCount(),
 		)
 		
+		// Nodes with higher priority get materials first
+		addNeededMaterial := func(n *model.Node, matType model.MaterialType, count uint) {
+			if other, ok := neededMaterials[matType]; ok && other.Node.Priority() >= n.Priority() {
+				return
+			}
+
+			neededMaterials[matType] = struct{Node *model.Node; Count uint}{n, count}
+		}
+		
 		for _, n := range playerGraph.BuildingNodes() {
+			if n.Priority() == model.PausedNodePriority {
+				continue
+			}
+
 			enoughMaterials := false
 			data := n.BuildingData()
 			for _, m := range n.InputMaterials() {
@@ -390,11 +1051,24 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 			}
 			
 			for matType, count := range data.Materials {
-				neededMaterials[matType] = struct{Node *model.Node; Count uint}{n, count}
+				addNeededMaterial(n, matType, count)
+			}
+		}
+
+		if r, ok := s.Researches[sessionID]; ok && !r.IsStarted {
+			n, missing := s.missingResearchMaterials(sessionID, r)
+			if n.Priority() != model.PausedNodePriority {
+				for _, matType := range slices.Sorted(maps.Keys(missing)) {
+					addNeededMaterial(n, matType, missing[matType])
+				}
 			}
 		}
 
 		for _, n := range playerGraph.NodesByType(model.ProductionNodeType, true) {
+			if n.Priority() == model.PausedNodePriority {
+				continue
+			}
+
 			enoughMaterials := false
 			data, ok := n.ProductionData()
 			assert.True(ok)
@@ -425,7 +1099,7 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 			}
 			
 			for matType, count := range data.InputMaterials {
-				neededMaterials[matType] = struct{Node *model.Node; Count uint}{n, count}
+				addNeededMaterial(n, matType, count)
 			}
 		}
 		
@@ -436,10 +1110,10 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 				return
 			}
 			
-			u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, u.Node(), n))
+			u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), u.Node(), n))
 		}
 		
-		for _, m := range playerMaterials {
+		for _, m := range sortedByID(playerMaterials) {
 			if !m.IsReserved() && !m.NodeData().IsInput {
 				matData, ok := neededMaterials[m.Type()]
 				if !ok {
@@ -447,26 +1121,8 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 				}
 				
 				m.Reserve()
-				
-				if u.Node() != m.NodeData().Node {
-					shortestPath := playerGraph.FindShortestPath(u.Node(), m.NodeData().Node)
-					for i := range len(shortestPath) - 1 {
-						n1, n2 := shortestPath[i], shortestPath[i + 1]
-						u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, n1, n2))
-					}
-				}
-				
-				u.Actions().PushBack(model.NewTakeMaterialUnitAction(m))
 
-				if u.Node() != matData.Node {
-					shortestPath := playerGraph.FindShortestPath(u.Node(), matData.Node)
-					for i := range len(shortestPath) - 1 {
-						n1, n2 := shortestPath[i], shortestPath[i + 1]
-						u.Actions().PushBack(model.NewMovingUnitAction(config.UnitSpeed, n1, n2))
-					}
-				}
-
-				u.Actions().PushBack(model.NewDropMaterialUnitAction())
+				s.pushHaulActions(playerGraph, u, m, matData.Node)
 				break
 			}
 		}
@@ -475,6 +1131,138 @@ func (s *State) pollActions(sessionID string, u *model.Unit) {
 	}
 }
 
+// pollCommandActions tries to add action to a unit that follows the player's command
+func (s *State) pollCommandActions(sessionID string, u *model.Unit) {
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+
+	// Unit should always have a node when polling for actions
+	assert.NotNil(u.Node())
+
+	cmd := u.Command()
+	switch cmd.Type {
+	case model.MoveUnitCommandType:
+		data, ok := cmd.Data.(*model.MoveUnitCommandData)
+		assert.True(ok)
+
+		// Once the unit is there it just stays at the node
+		s.pushMovingActions(playerGraph, u, u.Node(), data.Node)
+	case model.WorkUnitCommandType:
+		data, ok := cmd.Data.(*model.WorkUnitCommandData)
+		assert.True(ok)
+		
+		s.pushMovingActions(playerGraph, u, u.Node(), data.Node)
+		s.pushProductionAction(u, data.Node)
+	case model.HaulUnitCommandType:
+		data, ok := cmd.Data.(*model.HaulUnitCommandData)
+		assert.True(ok)
+		
+		for _, m := range sortedByID(data.FromNode.OutputMaterials()) {
+			if m.IsReserved() {
+				continue
+			}
+
+			if data.MaterialType != 0 && m.Type() != data.MaterialType {
+				continue
+			}
+			
+			m.Reserve()
+
+			s.pushHaulActions(playerGraph, u, m, data.ToNode)
+			return
+		}
+
+		// Nothing to carry right now, wait for materials at the source
+		s.pushMovingActions(playerGraph, u, u.Node(), data.FromNode)
+	default:
+		panic("unreachable")
+	}
+}
+
+// pushMovingActions adds moving actions along the shortest path between two nodes
+func (s *State) pushMovingActions(playerGraph *graph.Graph, u *model.Unit, fromNode, toNode *model.Node) {
+	if fromNode.ID() == toNode.ID() {
+		return
+	}
+
+	shortestPath := playerGraph.FindShortestPath(fromNode, toNode)
+	for i := range len(shortestPath) - 1 {
+		n1, n2 := shortestPath[i], shortestPath[i + 1]
+		u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), n1, n2))
+	}
+}
+
+// pushProductionAction adds production action if the node has enough input materials
+func (s *State) pushProductionAction(u *model.Unit, n *model.Node) {
+	data, ok := n.ProductionData()
+	assert.True(ok)
+	
+	// Node that is being upgraded doesn't start new production
+	if !n.IsBuilt() {
+		return
+	}
+	
+	// Units are not produced over the population cap
+	if data.OutputUnits > 0 && !s.hasRoom(u.SessionID()) {
+		return
+	}
+	
+	inputMaterials := make([]*model.Material, 0, len(data.InputMaterials))
+
+	// Nodes without inputs, like GrassField, produce from nothing
+	enoughMaterials := len(data.InputMaterials) == 0
+	if !enoughMaterials {
+		for _, m := range sortedByID(n.InputMaterials()) {
+			// Other unit of the node is already using it
+			if m.IsReserved() {
+				continue
+			}
+
+			c, exists := data.InputMaterials[m.Type()];
+			if !exists {
+				continue
+			}
+			// Ensure that there's at least 1 input material to build a node
+			assert.NotEquals(c, 0)
+
+			c -= 1
+			if c == 0 {
+				delete(data.InputMaterials, m.Type())
+			} else {
+				data.InputMaterials[m.Type()] = c
+			}
+
+			inputMaterials = append(inputMaterials, m)
+
+			if len(data.InputMaterials) == 0 {
+				enoughMaterials = true
+				break
+			}
+		}
+	}
+	
+	if !enoughMaterials {
+		return 
+	}
+	
+	for _, m := range inputMaterials {
+		m.Reserve()
+	}
+
+	u.Actions().PushBack(model.NewProductionUnitAction(inputMaterials))
+}
+
+// pushHaulActions adds actions to carry already reserved material to the node
+func (s *State) pushHaulActions(playerGraph *graph.Graph, u *model.Unit, m *model.Material, toNode *model.Node) {
+	fromNode := m.NodeData().Node
+
+	s.pushMovingActions(playerGraph, u, u.Node(), fromNode)
+	u.Actions().PushBack(model.NewTakeMaterialUnitAction(m))
+
+	s.pushMovingActions(playerGraph, u, fromNode, toNode)
+	u.Actions().PushBack(model.NewDropMaterialUnitAction())
+}
+
 func (s *State) executeUnitAction(sessionID string, u *model.Unit, action *model.UnitAction) bool {
 	playerUnits, ok := s.Units[sessionID]
 	assert.True(ok)
@@ -497,8 +1285,7 @@ func (s *State) executeUnitAction(sessionID string, u *model.Unit, action *model
 			u.Node().RemoveUnit(u)
 		}
 
-		dist := vec2.Distance(data.FromNode.Position(), data.ToNode.Position())
-		data.Progress += data.Speed / dist
+		data.Progress += s.TickMs() / data.TimeMs
 		
 		if data.Progress >= 1.0 {
 			data.ToNode.AddUnit(u)
@@ -514,7 +1301,7 @@ func (s *State) executeUnitAction(sessionID string, u *model.Unit, action *model
 		prodData, ok := u.Node().ProductionData()
 		assert.True(ok)
 
-		uaData.Progress += prodData.ProgressInc
+		uaData.Progress += s.TickMs() / prodData.TimeMs
 		
 		if uaData.Progress >= 1.0 {
 			uaData.Progress = 1.0
@@ -533,7 +1320,8 @@ func (s *State) executeUnitAction(sessionID string, u *model.Unit, action *model
 			}
 
 			if prodData.OutputMaterials != nil {
-				for typ, count := range prodData.OutputMaterials {
+				for _, typ := range slices.Sorted(maps.Keys(prodData.OutputMaterials)) {
+					count := prodData.OutputMaterials[typ]
 					for range count {
 						materialID, ok := s.NextMaterialIDs[sessionID]
 						assert.True(ok)
@@ -577,11 +1365,22 @@ func (s *State) executeUnitAction(sessionID string, u *model.Unit, action *model
 		
 		return false
 	case model.BuildingUnitActionType:
-		const progressIncrement = 0.1
-		u.Node().Build(progressIncrement)
+		// Builder leaves the node once it's paused
+		if u.Node().Priority() == model.PausedNodePriority {
+			return true
+		}
+
+		u.Node().Build(s.TickMs() / s.Factions[sessionID].BuildTimeMs())
 		
 		if u.Node().IsBuilt() {
-			for _, m := range u.Node().InputMaterials() {
+			data := u.Node().BuildingData()
+			for _, m := range sortedByID(u.Node().InputMaterials()) {
+				// Reserved materials are used by the production that started before the upgrade
+				if m.IsReserved() || data.Materials[m.Type()] == 0 {
+					continue
+				}
+				data.Materials[m.Type()] -= 1
+
 				m.NodeData().Node.RemoveInputMaterial(m)
 				delete(playerMaterials, m.ID())
 
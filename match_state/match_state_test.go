@@ -0,0 +1,134 @@
+package match_state
+
+import (
+	"testing"
+
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/rules"
+)
+
+func newTestState(t *testing.T, r *rules.Rules, sessionIDs ...string) *State {
+	t.Helper()
+
+	s, err := New(sessionIDs, nil, r, 1)
+	if err != nil {
+		t.Fatalf("can't create state: %v", err)
+	}
+
+	return s
+}
+
+func rootNode(t *testing.T, s *State, sessionID string) *model.Node {
+	t.Helper()
+
+	n, err := s.playerNode(sessionID, model.ID(1))
+	if err != nil {
+		t.Fatalf("can't get root node: %v", err)
+	}
+
+	return n
+}
+
+func countUnits(s *State, sessionID string, typ model.UnitType) int {
+	count := 0
+	for _, u := range s.Units[sessionID] {
+		if u.Type() == typ {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+func TestSetRoleQuotaValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		quota map[model.UnitType]uint
+		wantErr bool
+	}{
+		{"empty", map[model.UnitType]uint{}, false},
+		{"sum is 100", map[model.UnitType]uint{model.IdleUnitType: 40, model.BuilderUnitType: 60}, false},
+		{"sum is below 100", map[model.UnitType]uint{model.IdleUnitType: 40, model.BuilderUnitType: 50}, true},
+		{"sum is over 100", map[model.UnitType]uint{model.IdleUnitType: 60, model.BuilderUnitType: 50}, true},
+		{"entry is over 100", map[model.UnitType]uint{model.IdleUnitType: 101}, true},
+		{"sum wraps around", map[model.UnitType]uint{model.IdleUnitType: ^uint(0), model.BuilderUnitType: 101}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestState(t, rules.Default(), "a")
+
+			err := s.SetRoleQuota("a", tt.quota)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetRoleQuota() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			_, ok := s.RoleQuotas["a"]
+			if want := !tt.wantErr && len(tt.quota) > 0; ok != want {
+				t.Errorf("quota is set = %v, want %v", ok, want)
+			}
+		})
+	}
+}
+
+func TestReconcileRoleQuota(t *testing.T) {
+	r := rules.Default()
+	r.Units = map[model.UnitType]uint{
+		model.IdleUnitType: 2,
+		model.BuilderUnitType: 2,
+		model.TransportUnitType: 4,
+	}
+	s := newTestState(t, r, "a")
+
+	quota := map[model.UnitType]uint{
+		model.BuilderUnitType: 50,
+		model.TransportUnitType: 50,
+	}
+	if err := s.SetRoleQuota("a", quota); err != nil {
+		t.Fatalf("can't set quota: %v", err)
+	}
+
+	// Idle units go first, one unit per call
+	s.reconcileRoleQuota("a", quota)
+	if got := countUnits(s, "a", model.IdleUnitType); got != 1 {
+		t.Fatalf("idle units after one call = %d, want 1", got)
+	}
+	if got := countUnits(s, "a", model.BuilderUnitType); got != 3 {
+		t.Fatalf("builder units after one call = %d, want 3", got)
+	}
+
+	for range 10 {
+		s.reconcileRoleQuota("a", quota)
+	}
+
+	want := map[model.UnitType]int{
+		model.IdleUnitType: 0,
+		model.BuilderUnitType: 4,
+		model.TransportUnitType: 4,
+	}
+	for typ, count := range want {
+		if got := countUnits(s, "a", typ); got != count {
+			t.Errorf("units of type %d = %d, want %d", typ, got, count)
+		}
+	}
+}
+
+func TestReconcileRoleQuotaSkipsBusyUnits(t *testing.T) {
+	r := rules.Default()
+	r.Units = map[model.UnitType]uint{
+		model.IdleUnitType: 2,
+	}
+	s := newTestState(t, r, "a")
+
+	root := rootNode(t, s, "a")
+	for _, u := range s.Units["a"] {
+		u.SetCommand(model.NewMoveUnitCommand(root))
+	}
+
+	quota := map[model.UnitType]uint{model.BuilderUnitType: 100}
+	s.reconcileRoleQuota("a", quota)
+
+	if got := countUnits(s, "a", model.IdleUnitType); got != 2 {
+		t.Errorf("idle units = %d, want 2", got)
+	}
+}
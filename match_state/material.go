@@ -0,0 +1,91 @@
+package match_state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/graph"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+func (s *State) playerNode(sessionID string, nodeID model.ID) (*model.Node, error) {
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+
+	n, err := playerGraph.Node(nodeID)
+	if errors.Is(err, graph.ErrVertexNotFound) {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	assert.NoError(err)
+
+	return n, nil
+}
+
+// freeMaterials returns count materials of the type that lie on the node and are not reserved
+func (s *State) freeMaterials(n *model.Node, typ model.MaterialType, count int) ([]*model.Material, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid count: %d", count)
+	}
+
+	out := make([]*model.Material, 0, count)
+	for _, m := range sortedByID(n.OutputMaterials()) {
+		if len(out) == count {
+			break
+		}
+
+		if m.Type() == typ && !m.IsReserved() {
+			out = append(out, m)
+		}
+	}
+
+	if len(out) < count {
+		return nil, fmt.Errorf("not enough free materials on the node %d: %d", n.ID(), len(out))
+	}
+
+	return out, nil
+}
+
+// moveMaterials moves free materials to the node of another player, they get new IDs of that player
+func (s *State) moveMaterials(sessionID, toSessionID string, materials []*model.Material, toNode *model.Node) []*model.Material {
+	playerMaterials, ok := s.Materials[sessionID]
+	assert.True(ok)
+
+	toMaterials, ok := s.Materials[toSessionID]
+	assert.True(ok)
+
+	out := make([]*model.Material, 0, len(materials))
+	for _, m := range materials {
+		m.NodeData().Node.RemoveOutputMaterial(m)
+		delete(playerMaterials, m.ID())
+
+		s.RespsWithOpcode[sessionID] = append(
+			s.RespsWithOpcode[sessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewMaterialDestroyedResp(m),
+				opcode.MaterialDestroyed,
+			),
+		)
+
+		materialID, ok := s.NextMaterialIDs[toSessionID]
+		assert.True(ok)
+
+		moved := model.NewMaterial(materialID, toSessionID, m.Type(), toNode, false)
+
+		toMaterials[materialID] = moved
+		s.NextMaterialIDs[toSessionID] += 1
+
+		s.RespsWithOpcode[toSessionID] = append(
+			s.RespsWithOpcode[toSessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewMaterialCreatedResp(moved),
+				opcode.MaterialCreated,
+			),
+		)
+
+		out = append(out, moved)
+	}
+
+	return out
+}
@@ -0,0 +1,107 @@
+package match_state
+
+import (
+	"fmt"
+
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/opcode"
+)
+
+// Pause stops executing ticks, empty sessionID means that the match is paused by an admin
+func (s *State) Pause(sessionID string) error {
+	if s.Paused {
+		return fmt.Errorf("match is already paused")
+	}
+
+	timeoutMs := uint(0)
+	if sessionID != "" {
+		pausesLeft, ok := s.PausesLeft[sessionID]
+		assert.True(ok)
+
+		if pausesLeft == 0 {
+			return fmt.Errorf("no pauses left")
+		}
+
+		s.PausesLeft[sessionID] -= 1
+		timeoutMs = s.MaxPauseMs
+	}
+
+	s.Paused = true
+	s.PausedBy = sessionID
+	s.PausedLoops = 0
+
+	// Enemies don't see who has paused the match and how many pauses are left
+	s.Events = append(
+		s.Events,
+		&Event{
+			opcode.NewRespWithOpCode(
+				opcode.NewPauseResp(sessionID, s.PausesLeft[sessionID], timeoutMs),
+				opcode.Pause,
+			),
+			sessionID,
+			opcode.NewPauseResp("", 0, timeoutMs),
+		},
+	)
+
+	return nil
+}
+
+// Resume continues executing ticks, any player can resume the pause of a player,
+// only an admin can resume the pause of an admin
+func (s *State) Resume(sessionID string) error {
+	if !s.Paused {
+		return fmt.Errorf("match is not paused")
+	}
+
+	if sessionID != "" && s.PausedBy == "" {
+		return fmt.Errorf("match is paused by an admin")
+	}
+
+	s.resume(sessionID, false)
+
+	return nil
+}
+
+// AdvancePause is called every match loop while the match is paused,
+// pause of a player is resumed when it's longer than the maximum pause time
+func (s *State) AdvancePause() {
+	assert.True(s.Paused)
+
+	s.PausedLoops += 1
+	if s.PausedBy == "" {
+		return
+	}
+
+	if s.PausedLoops * 1000 >= int(s.MaxPauseMs) * config.TickRate {
+		s.ExpirePause()
+	}
+}
+
+// ExpirePause resumes the match as if the pause has timed out, the expiry is recorded,
+// because it depends on the time of the match loop and not on ticks
+func (s *State) ExpirePause() {
+	assert.True(s.Paused)
+
+	s.Replay.RecordPauseExpiry(s.CurrentTick)
+
+	s.resume("", true)
+}
+
+func (s *State) resume(sessionID string, isTimeout bool) {
+	s.Paused = false
+	s.PausedBy = ""
+	s.PausedLoops = 0
+
+	s.Events = append(
+		s.Events,
+		&Event{
+			opcode.NewRespWithOpCode(
+				opcode.NewResumeResp(sessionID, isTimeout),
+				opcode.Resume,
+			),
+			sessionID,
+			opcode.NewResumeResp("", isTimeout),
+		},
+	)
+}
@@ -0,0 +1,73 @@
+package match_state
+
+import (
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+// MaxPopulation is the population cap of the player, housing nodes raise it
+func (s *State) MaxPopulation(sessionID string) int {
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+
+	out := config.BasePopulation
+	for _, n := range playerGraph.Nodes() {
+		out += n.Housing()
+	}
+
+	return out
+}
+
+// pendingUnits counts units that are being produced or are planned to be produced by workers
+func (s *State) pendingUnits(sessionID string) int {
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+
+	out := 0
+	for _, u := range playerUnits {
+		// Production is planned on the node where the moves before it end
+		n := u.Node()
+		for i := range u.Actions().Len() {
+			a := u.Actions().At(i)
+			switch a.Type {
+			case model.MovingUnitActionType:
+				data, ok := a.Data.(*model.MovingUnitActionData)
+				assert.True(ok)
+
+				n = data.ToNode
+			case model.ProductionUnitActionType:
+				if data, ok := n.ProductionData(); ok && data.OutputUnits > 0 {
+					out += int(data.OutputUnits)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// hasRoom checks if the player can produce one more unit without going over the population cap
+func (s *State) hasRoom(sessionID string) bool {
+	return len(s.Units[sessionID]) + s.pendingUnits(sessionID) < s.MaxPopulation(sessionID)
+}
+
+// updatePopulations sends populations of players that changed during the tick
+func (s *State) updatePopulations() {
+	for _, sessionID := range s.SessionIDs {
+		p := model.NewPopulation(len(s.Units[sessionID]), s.MaxPopulation(sessionID))
+		if old, ok := s.Populations[sessionID]; ok && *old == *p {
+			continue
+		}
+		s.Populations[sessionID] = p
+
+		s.RespsWithOpcode[sessionID] = append(
+			s.RespsWithOpcode[sessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewPopulationStatsResp(sessionID, p),
+				opcode.PopulationStats,
+			),
+		)
+	}
+}
@@ -0,0 +1,119 @@
+package match_state
+
+import (
+	"testing"
+
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/rules"
+	"github.com/relby/achikaps/vec2"
+)
+
+func newPopulationState(t *testing.T, units uint) *State {
+	t.Helper()
+
+	r := rules.Default()
+	r.StartTransitNodes = 0
+	r.Units = map[model.UnitType]uint{model.IdleUnitType: units}
+
+	return newTestState(t, r, "a")
+}
+
+func addIncubator(t *testing.T, s *State, sessionID string, built bool) *model.Node {
+	t.Helper()
+
+	root := rootNode(t, s, sessionID)
+	n := model.NewNode(s.NextNodeIDs[sessionID], sessionID, model.DefaultFaction, model.IncubatorNodeName, root.Position().Add(vec2.New(config.MinNodeDistance, 0)))
+	if built {
+		n.BuildFully()
+	}
+
+	if err := s.Graphs[sessionID].AddNodeFrom(root, n); err != nil {
+		t.Fatalf("can't add incubator: %v", err)
+	}
+	s.NextNodeIDs[sessionID] += 1
+
+	return n
+}
+
+func TestMaxPopulation(t *testing.T) {
+	s := newPopulationState(t, 1)
+
+	if got := s.MaxPopulation("a"); got != config.BasePopulation {
+		t.Fatalf("max population = %d, want %d", got, config.BasePopulation)
+	}
+
+	// Housing only counts when it's built
+	addIncubator(t, s, "a", false)
+	if got := s.MaxPopulation("a"); got != config.BasePopulation {
+		t.Errorf("max population with unbuilt housing = %d, want %d", got, config.BasePopulation)
+	}
+
+	addIncubator(t, s, "a", true)
+	if got, want := s.MaxPopulation("a"), config.BasePopulation + config.HousingPopulation; got != want {
+		t.Errorf("max population with housing = %d, want %d", got, want)
+	}
+}
+
+func TestHasRoom(t *testing.T) {
+	tests := []struct {
+		name string
+		units uint
+		want bool
+	}{
+		{"below the cap", uint(config.BasePopulation - 1), true},
+		{"at the cap", uint(config.BasePopulation), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPopulationState(t, tt.units)
+
+			if got := s.hasRoom("a"); got != tt.want {
+				t.Errorf("hasRoom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasRoomCountsPendingUnits(t *testing.T) {
+	// Incubator is housing as well
+	s := newPopulationState(t, uint(config.BasePopulation + config.HousingPopulation - 1))
+	incubator := addIncubator(t, s, "a", true)
+
+	data, ok := incubator.ProductionData()
+	if !ok || data.OutputUnits == 0 {
+		t.Fatal("incubator doesn't produce units")
+	}
+
+	// Production is counted on the node where the unit moves to
+	u := s.Units["a"][1]
+	u.Actions().PushBack(model.NewMovingUnitAction(s.unitSpeed(u), u.Node(), incubator))
+	u.Actions().PushBack(model.NewProductionUnitAction(nil))
+
+	if got := s.pendingUnits("a"); got != int(data.OutputUnits) {
+		t.Errorf("pending units = %d, want %d", got, data.OutputUnits)
+	}
+	if s.hasRoom("a") {
+		t.Error("hasRoom() = true with pending units at the cap")
+	}
+}
+
+func TestUpdatePopulations(t *testing.T) {
+	s := newPopulationState(t, 1)
+
+	s.updatePopulations()
+	if got := countResps(s, "a", opcode.PopulationStats); got != 0 {
+		t.Fatalf("population stats without changes = %d, want 0", got)
+	}
+
+	addIncubator(t, s, "a", true)
+	s.updatePopulations()
+	if got := countResps(s, "a", opcode.PopulationStats); got != 1 {
+		t.Fatalf("population stats after housing is built = %d, want 1", got)
+	}
+	if p := s.Populations["a"]; *p != *model.NewPopulation(1, config.BasePopulation + config.HousingPopulation) {
+		t.Errorf("population = %+v, want 1 of %d", *p, config.BasePopulation + config.HousingPopulation)
+	}
+}
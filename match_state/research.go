@@ -0,0 +1,139 @@
+package match_state
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+// ErrNodeLocked is returned when the player builds a node that is not unlocked by research yet
+var ErrNodeLocked = errors.New("node is not unlocked")
+
+// IsUnlocked checks if the player has researched the node
+func (s *State) IsUnlocked(sessionID string, name model.NodeName) bool {
+	return s.TechTree.IsUnlocked(name, s.Techs[sessionID])
+}
+
+// StartResearch makes transporters bring materials of the tech to the research node,
+// player researches one tech at a time
+func (s *State) StartResearch(sessionID string, techID, nodeID model.ID) (*model.Research, error) {
+	tech, ok := s.TechTree.Tech(techID)
+	if !ok {
+		return nil, fmt.Errorf("tech with id %d not found", techID)
+	}
+
+	if s.Techs[sessionID][techID] {
+		return nil, fmt.Errorf("tech with id %d is already researched", techID)
+	}
+
+	if !s.TechTree.IsAvailable(tech, s.Techs[sessionID]) {
+		return nil, fmt.Errorf("required techs are not researched")
+	}
+
+	if _, ok := s.Researches[sessionID]; ok {
+		return nil, fmt.Errorf("another tech is being researched")
+	}
+
+	n, err := s.playerNode(sessionID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Type() != model.ResearchNodeType {
+		return nil, fmt.Errorf("node with id %d is not a research node", nodeID)
+	}
+
+	if !n.IsBuilt() {
+		return nil, fmt.Errorf("node with id %d is not built", nodeID)
+	}
+
+	r := model.NewResearch(techID, nodeID)
+	s.Researches[sessionID] = r
+
+	return r, nil
+}
+
+// missingResearchMaterials returns materials of the research that are not at the research node yet
+func (s *State) missingResearchMaterials(sessionID string, r *model.Research) (*model.Node, map[model.MaterialType]uint) {
+	tech, ok := s.TechTree.Tech(r.TechID)
+	assert.True(ok)
+
+	n, err := s.playerNode(sessionID, r.NodeID)
+	assert.NoError(err)
+
+	missing := maps.Clone(tech.Materials)
+	for _, m := range n.InputMaterials() {
+		if m.IsReserved() || missing[m.Type()] == 0 {
+			continue
+		}
+
+		missing[m.Type()] -= 1
+		if missing[m.Type()] == 0 {
+			delete(missing, m.Type())
+		}
+	}
+
+	return n, missing
+}
+
+// advanceResearches consumes materials of the researches that have all of them and moves them forward
+func (s *State) advanceResearches() {
+	for _, sessionID := range s.SessionIDs {
+		r, ok := s.Researches[sessionID]
+		if !ok {
+			continue
+		}
+
+		tech, ok := s.TechTree.Tech(r.TechID)
+		assert.True(ok)
+
+		if !r.IsStarted {
+			n, missing := s.missingResearchMaterials(sessionID, r)
+			if len(missing) != 0 {
+				continue
+			}
+
+			needed := maps.Clone(tech.Materials)
+			for _, m := range sortedByID(n.InputMaterials()) {
+				if m.IsReserved() || needed[m.Type()] == 0 {
+					continue
+				}
+				needed[m.Type()] -= 1
+
+				n.RemoveInputMaterial(m)
+				delete(s.Materials[sessionID], m.ID())
+
+				s.RespsWithOpcode[sessionID] = append(
+					s.RespsWithOpcode[sessionID],
+					opcode.NewRespWithOpCode(
+						opcode.NewMaterialDestroyedResp(m),
+						opcode.MaterialDestroyed,
+					),
+				)
+			}
+
+			r.IsStarted = true
+		}
+
+		r.Progress += s.TickMs() / tech.TimeMs
+		if r.Progress < 1.0 {
+			continue
+		}
+
+		s.Techs[sessionID][r.TechID] = true
+		delete(s.Researches, sessionID)
+
+		s.RespsWithOpcode[sessionID] = append(
+			s.RespsWithOpcode[sessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewResearchCompletedResp(sessionID, tech.ID, slices.Clone(tech.Unlocks)),
+				opcode.ResearchCompleted,
+			),
+		)
+	}
+}
@@ -0,0 +1,56 @@
+package match_state
+
+import (
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/model"
+)
+
+// PlayerStats is a summary of the player's economy
+type PlayerStats struct {
+	Nodes int
+	BuiltNodes int
+	Units map[model.UnitType]int
+	Materials map[model.MaterialType]int
+	Population int
+	MaxPopulation int
+	// Units that missed the last meal
+	StarvingUnits int
+	// Food that units can eat on the next meal
+	Food int
+}
+
+func (s *State) Stats(sessionID string) *PlayerStats {
+	playerGraph, ok := s.Graphs[sessionID]
+	assert.True(ok)
+
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+
+	playerMaterials, ok := s.Materials[sessionID]
+	assert.True(ok)
+	
+	stats := &PlayerStats{
+		Nodes: playerGraph.NodeCount(),
+		BuiltNodes: playerGraph.NodeCount() - len(playerGraph.BuildingNodes()),
+		Units: make(map[model.UnitType]int),
+		Materials: make(map[model.MaterialType]int),
+		Population: len(playerUnits),
+		MaxPopulation: s.MaxPopulation(sessionID),
+		StarvingUnits: 0,
+		Food: len(s.freeFood(sessionID)),
+	}
+	
+	for _, u := range playerUnits {
+		stats.Units[u.Type()] += 1
+
+		if u.IsStarving() {
+			stats.StarvingUnits += 1
+		}
+	}
+
+	for _, m := range playerMaterials {
+		stats.Materials[m.Type()] += 1
+	}
+	
+	return stats
+}
@@ -0,0 +1,88 @@
+package match_state
+
+import (
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/model"
+)
+
+func (s *State) Team(sessionID string) uint {
+	team, ok := s.Teams[sessionID]
+	assert.True(ok)
+
+	return team
+}
+
+func (s *State) IsAlly(sessionID1, sessionID2 string) bool {
+	return s.Team(sessionID1) == s.Team(sessionID2)
+}
+
+// Recipients returns presences that see updates of the player, nil means everyone.
+// It returns false if none of them is connected
+func (s *State) Recipients(sessionID string) ([]runtime.Presence, bool) {
+	if !s.FogOfWar {
+		return nil, true
+	}
+
+	out := make([]runtime.Presence, 0, len(s.Presences) + len(s.Spectators))
+	for _, id := range s.SessionIDs {
+		p, ok := s.Presences[id]
+		if ok && s.IsAlly(sessionID, id) {
+			out = append(out, p)
+		}
+	}
+
+	for _, p := range s.Spectators {
+		out = append(out, p)
+	}
+
+	return out, len(out) > 0
+}
+
+// Enemies returns connected players that don't see updates of the player under fog of war
+func (s *State) Enemies(sessionID string) []runtime.Presence {
+	out := make([]runtime.Presence, 0, len(s.Presences))
+	for _, id := range s.SessionIDs {
+		p, ok := s.Presences[id]
+		if ok && !s.IsAlly(sessionID, id) {
+			out = append(out, p)
+		}
+	}
+
+	return out
+}
+
+// SendMaterials moves free materials from the node of the player to the root node of the ally
+func (s *State) SendMaterials(sessionID, toSessionID string, nodeID model.ID, typ model.MaterialType, count int) ([]*model.Material, error) {
+	if !s.IsPlayer(toSessionID) {
+		return nil, fmt.Errorf("player %s not found", toSessionID)
+	}
+
+	if sessionID == toSessionID {
+		return nil, fmt.Errorf("can't send materials to yourself")
+	}
+
+	if !s.IsAlly(sessionID, toSessionID) {
+		return nil, fmt.Errorf("player %s is not an ally", toSessionID)
+	}
+
+	n, err := s.playerNode(sessionID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	free, err := s.freeMaterials(n, typ, count)
+	if err != nil {
+		return nil, err
+	}
+
+	allyGraph, ok := s.Graphs[toSessionID]
+	assert.True(ok)
+
+	root, err := allyGraph.Node(model.ID(1))
+	assert.NoError(err)
+
+	return s.moveMaterials(sessionID, toSessionID, free, root), nil
+}
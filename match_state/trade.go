@@ -0,0 +1,97 @@
+package match_state
+
+import (
+	"fmt"
+
+	"github.com/relby/achikaps/model"
+)
+
+// Player can have at most this number of offers that are not accepted or rejected
+const maxPendingTrades = 5
+
+// OfferTrade creates the offer, materials are checked again when it's accepted
+func (s *State) OfferTrade(sessionID, toSessionID string, nodeID model.ID, giveType model.MaterialType, giveCount int, wantType model.MaterialType, wantCount int) (*model.Trade, error) {
+	if !s.IsPlayer(toSessionID) {
+		return nil, fmt.Errorf("player %s not found", toSessionID)
+	}
+
+	if sessionID == toSessionID {
+		return nil, fmt.Errorf("can't trade with yourself")
+	}
+
+	if giveCount <= 0 || wantCount <= 0 {
+		return nil, fmt.Errorf("invalid count")
+	}
+
+	pending := 0
+	for _, t := range s.Trades {
+		if t.FromSessionID == sessionID {
+			pending += 1
+		}
+	}
+	if pending >= maxPendingTrades {
+		return nil, fmt.Errorf("too many pending offers")
+	}
+
+	n, err := s.playerNode(sessionID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.freeMaterials(n, giveType, giveCount); err != nil {
+		return nil, err
+	}
+
+	t := model.NewTrade(s.NextTradeID, sessionID, toSessionID, nodeID, giveType, giveCount, wantType, wantCount)
+	s.Trades[t.ID] = t
+	s.NextTradeID += 1
+
+	return t, nil
+}
+
+// AcceptTrade exchanges materials, either both sides have enough free materials or nothing changes
+func (s *State) AcceptTrade(sessionID string, id model.ID, nodeID model.ID) (*model.Trade, error) {
+	t, ok := s.Trades[id]
+	if !ok || t.ToSessionID != sessionID {
+		return nil, fmt.Errorf("trade with id %d not found", id)
+	}
+
+	toNode, err := s.playerNode(sessionID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	want, err := s.freeMaterials(toNode, t.WantType, t.WantCount)
+	if err != nil {
+		return nil, err
+	}
+
+	fromNode, err := s.playerNode(t.FromSessionID, t.FromNodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	give, err := s.freeMaterials(fromNode, t.GiveType, t.GiveCount)
+	if err != nil {
+		return nil, fmt.Errorf("offer can't be fulfilled: %w", err)
+	}
+
+	s.moveMaterials(t.FromSessionID, sessionID, give, toNode)
+	s.moveMaterials(sessionID, t.FromSessionID, want, fromNode)
+
+	delete(s.Trades, id)
+
+	return t, nil
+}
+
+// RejectTrade is used by the receiver to reject the offer and by the sender to cancel it
+func (s *State) RejectTrade(sessionID string, id model.ID) (*model.Trade, error) {
+	t, ok := s.Trades[id]
+	if !ok || (t.ToSessionID != sessionID && t.FromSessionID != sessionID) {
+		return nil, fmt.Errorf("trade with id %d not found", id)
+	}
+
+	delete(s.Trades, id)
+
+	return t, nil
+}
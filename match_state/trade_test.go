@@ -0,0 +1,197 @@
+package match_state
+
+import (
+	"testing"
+
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/rules"
+)
+
+func countMaterials(n *model.Node, typ model.MaterialType) int {
+	count := 0
+	for _, m := range n.OutputMaterials() {
+		if m.Type() == typ {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+func newTradeState(t *testing.T) *State {
+	t.Helper()
+
+	r := rules.Default()
+	r.Materials = map[model.MaterialType]uint{
+		model.GrassMaterialType: 5,
+		model.SandMaterialType: 5,
+	}
+
+	return newTestState(t, r, "a", "b")
+}
+
+func TestAcceptTradeMovesMaterials(t *testing.T) {
+	s := newTradeState(t)
+
+	trade, err := s.OfferTrade("a", "b", 1, model.GrassMaterialType, 2, model.SandMaterialType, 3)
+	if err != nil {
+		t.Fatalf("can't offer trade: %v", err)
+	}
+
+	// Offered materials stay with the sender until the trade is accepted
+	if got := countMaterials(rootNode(t, s, "a"), model.GrassMaterialType); got != 5 {
+		t.Fatalf("grass of a after offer = %d, want 5", got)
+	}
+
+	if _, err := s.AcceptTrade("b", trade.ID, 1); err != nil {
+		t.Fatalf("can't accept trade: %v", err)
+	}
+
+	want := map[string]map[model.MaterialType]int{
+		"a": {model.GrassMaterialType: 3, model.SandMaterialType: 8},
+		"b": {model.GrassMaterialType: 7, model.SandMaterialType: 2},
+	}
+	for sessionID, counts := range want {
+		root := rootNode(t, s, sessionID)
+		for typ, count := range counts {
+			if got := countMaterials(root, typ); got != count {
+				t.Errorf("materials of type %d of %s = %d, want %d", typ, sessionID, got, count)
+			}
+		}
+
+	}
+
+	// Moved materials are dropped from the sender and get IDs of the receiver
+	if got := len(s.Materials["a"]); got != 11 {
+		t.Errorf("materials of a = %d, want 11", got)
+	}
+	if got := len(s.Materials["b"]); got != 9 {
+		t.Errorf("materials of b = %d, want 9", got)
+	}
+
+	if len(s.Trades) != 0 {
+		t.Errorf("trades = %d, want 0", len(s.Trades))
+	}
+}
+
+func TestAcceptTradeWithoutMaterialsChangesNothing(t *testing.T) {
+	s := newTradeState(t)
+
+	trade, err := s.OfferTrade("a", "b", 1, model.GrassMaterialType, 5, model.SandMaterialType, 5)
+	if err != nil {
+		t.Fatalf("can't offer trade: %v", err)
+	}
+
+	// Sender has spent the offered materials before the trade is accepted
+	root := rootNode(t, s, "a")
+	for _, m := range root.OutputMaterials() {
+		if m.Type() == model.GrassMaterialType {
+			m.Reserve()
+			break
+		}
+	}
+
+	if _, err := s.AcceptTrade("b", trade.ID, 1); err == nil {
+		t.Fatal("accepted trade that can't be fulfilled")
+	}
+
+	for _, sessionID := range []string{"a", "b"} {
+		root := rootNode(t, s, sessionID)
+		for _, typ := range []model.MaterialType{model.GrassMaterialType, model.SandMaterialType} {
+			if got := countMaterials(root, typ); got != 5 {
+				t.Errorf("materials of type %d of %s = %d, want 5", typ, sessionID, got)
+			}
+		}
+	}
+
+	if _, ok := s.Trades[trade.ID]; !ok {
+		t.Error("failed accept removed the trade")
+	}
+}
+
+func TestOfferTradeValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		to string
+		giveCount int
+		wantCount int
+	}{
+		{"yourself", "a", 1, 1},
+		{"unknown player", "c", 1, 1},
+		{"zero give count", "b", 0, 1},
+		{"negative want count", "b", 1, -1},
+		{"not enough materials", "b", 6, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTradeState(t)
+
+			if _, err := s.OfferTrade("a", tt.to, 1, model.GrassMaterialType, tt.giveCount, model.SandMaterialType, tt.wantCount); err == nil {
+				t.Error("OfferTrade() should fail")
+			}
+			if len(s.Trades) != 0 {
+				t.Errorf("trades = %d, want 0", len(s.Trades))
+			}
+		})
+	}
+}
+
+func TestOfferTradePendingLimit(t *testing.T) {
+	s := newTradeState(t)
+
+	for i := range maxPendingTrades {
+		if _, err := s.OfferTrade("a", "b", 1, model.GrassMaterialType, 1, model.SandMaterialType, 1); err != nil {
+			t.Fatalf("can't offer trade %d: %v", i, err)
+		}
+	}
+
+	if _, err := s.OfferTrade("a", "b", 1, model.GrassMaterialType, 1, model.SandMaterialType, 1); err == nil {
+		t.Fatal("offer over the limit should fail")
+	}
+
+	// Offers of other players don't count
+	if _, err := s.OfferTrade("b", "a", 1, model.GrassMaterialType, 1, model.SandMaterialType, 1); err != nil {
+		t.Errorf("can't offer trade from another player: %v", err)
+	}
+}
+
+func TestRejectTrade(t *testing.T) {
+	tests := []struct {
+		name string
+		sessionID string
+		wantErr bool
+	}{
+		{"receiver rejects", "b", false},
+		{"sender cancels", "a", false},
+		{"other player", "c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rules.Default()
+			s := newTestState(t, r, "a", "b", "c")
+
+			trade, err := s.OfferTrade("a", "b", 1, model.GrassMaterialType, 1, model.SandMaterialType, 1)
+			if err != nil {
+				t.Fatalf("can't offer trade: %v", err)
+			}
+
+			_, err = s.RejectTrade(tt.sessionID, trade.ID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RejectTrade() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, ok := s.Trades[trade.ID]; ok != tt.wantErr {
+				t.Errorf("trade exists = %v, want %v", ok, tt.wantErr)
+			}
+
+			// Rejected trade can't be accepted
+			if !tt.wantErr {
+				if _, err := s.AcceptTrade("b", trade.ID, 1); err == nil {
+					t.Error("accepted rejected trade")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package match_state
+
+import (
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+// unitSpeed is the distance per second that the unit moves, starving units are slower
+func (s *State) unitSpeed(u *model.Unit) float64 {
+	speed := s.Factions[u.SessionID()].UnitSpeed()
+	if u.IsStarving() {
+		speed *= config.StarvingSpeed
+	}
+
+	return speed
+}
+
+// freeFood returns food materials that lie on nodes and are not reserved, in the order they are eaten
+func (s *State) freeFood(sessionID string) []*model.Material {
+	playerMaterials, ok := s.Materials[sessionID]
+	assert.True(ok)
+
+	out := make([]*model.Material, 0)
+	for _, m := range sortedByID(playerMaterials) {
+		if !m.Type().IsFood() || m.IsReserved() || m.NodeData() == nil || m.NodeData().IsInput {
+			continue
+		}
+
+		out = append(out, m)
+	}
+
+	return out
+}
+
+// advanceUpkeep makes all units eat at the same time once per upkeep interval
+func (s *State) advanceUpkeep() {
+	s.UpkeepProgress += s.TickMs() / config.UpkeepIntervalMs
+	if s.UpkeepProgress < 1.0 {
+		return
+	}
+	s.UpkeepProgress -= 1.0
+
+	for _, sessionID := range s.SessionIDs {
+		s.feedUnits(sessionID)
+	}
+}
+
+// feedUnits gives one food material to every unit, units with lower IDs eat first.
+// Units that are left without food starve and die after missing too many meals
+func (s *State) feedUnits(sessionID string) {
+	playerUnits, ok := s.Units[sessionID]
+	assert.True(ok)
+
+	playerMaterials, ok := s.Materials[sessionID]
+	assert.True(ok)
+
+	food := s.freeFood(sessionID)
+	for _, u := range sortedByID(playerUnits) {
+		if len(food) != 0 {
+			m := food[0]
+			food = food[1:]
+
+			m.NodeData().Node.RemoveOutputMaterial(m)
+			delete(playerMaterials, m.ID())
+
+			s.RespsWithOpcode[sessionID] = append(
+				s.RespsWithOpcode[sessionID],
+				opcode.NewRespWithOpCode(
+					opcode.NewMaterialDestroyedResp(m),
+					opcode.MaterialDestroyed,
+				),
+			)
+
+			if !u.IsStarving() {
+				continue
+			}
+			u.Feed()
+
+			s.RespsWithOpcode[sessionID] = append(
+				s.RespsWithOpcode[sessionID],
+				opcode.NewRespWithOpCode(
+					opcode.NewUnitHungerChangedResp(u, false),
+					opcode.UnitHungerChanged,
+				),
+			)
+			continue
+		}
+
+		u.Starve()
+
+		isDead := u.Hunger() >= config.StarvationMeals
+		if isDead {
+			s.removeUnit(u)
+		}
+
+		s.RespsWithOpcode[sessionID] = append(
+			s.RespsWithOpcode[sessionID],
+			opcode.NewRespWithOpCode(
+				opcode.NewUnitHungerChangedResp(u, isDead),
+				opcode.UnitHungerChanged,
+			),
+		)
+	}
+}
+
+// removeUnit takes the unit out of the match, the material it carries is dropped on the node
+// and sent to the clients again, because it wasn't on the node before
+func (s *State) removeUnit(u *model.Unit) {
+	var carried *model.Material
+	if u.Type() == model.TransportUnitType {
+		carried = u.Material()
+	}
+
+	u.Kill()
+	delete(s.Units[u.SessionID()], u.ID())
+
+	if carried != nil {
+		s.RespsWithOpcode[u.SessionID()] = append(
+			s.RespsWithOpcode[u.SessionID()],
+			opcode.NewRespWithOpCode(
+				opcode.NewMaterialCreatedResp(carried),
+				opcode.MaterialCreated,
+			),
+		)
+	}
+}
@@ -0,0 +1,151 @@
+package match_state
+
+import (
+	"testing"
+
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/rules"
+)
+
+func newUpkeepState(t *testing.T, units map[model.UnitType]uint, food uint) *State {
+	t.Helper()
+
+	r := rules.Default()
+	r.Units = units
+	r.Materials = map[model.MaterialType]uint{
+		model.SugarMaterialType: food,
+		model.GrassMaterialType: 5,
+	}
+
+	return newTestState(t, r, "a")
+}
+
+func countResps(s *State, sessionID string, op opcode.OpCode) int {
+	count := 0
+	for _, r := range s.RespsWithOpcode[sessionID] {
+		if r.OpCode == op {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+func TestFeedUnitsEatsFood(t *testing.T) {
+	s := newUpkeepState(t, map[model.UnitType]uint{model.IdleUnitType: 3}, 5)
+
+	s.feedUnits("a")
+
+	if got := countMaterials(rootNode(t, s, "a"), model.SugarMaterialType); got != 2 {
+		t.Errorf("food left = %d, want 2", got)
+	}
+	if got := len(s.Materials["a"]); got != 7 {
+		t.Errorf("materials = %d, want 7", got)
+	}
+	for _, u := range s.Units["a"] {
+		if u.IsStarving() {
+			t.Errorf("unit %d is starving", u.ID())
+		}
+	}
+}
+
+func TestFeedUnitsSkipsReservedFood(t *testing.T) {
+	s := newUpkeepState(t, map[model.UnitType]uint{model.IdleUnitType: 1}, 1)
+
+	for _, m := range s.Materials["a"] {
+		if m.Type().IsFood() {
+			m.Reserve()
+		}
+	}
+
+	s.feedUnits("a")
+
+	if got := countMaterials(rootNode(t, s, "a"), model.SugarMaterialType); got != 1 {
+		t.Errorf("food left = %d, want 1", got)
+	}
+	for _, u := range s.Units["a"] {
+		if !u.IsStarving() {
+			t.Errorf("unit %d ate reserved food", u.ID())
+		}
+	}
+}
+
+func TestFeedUnitsStarvation(t *testing.T) {
+	s := newUpkeepState(t, map[model.UnitType]uint{model.IdleUnitType: 3}, 1)
+
+	for range config.StarvationMeals - 1 {
+		s.feedUnits("a")
+	}
+
+	// Units with lower IDs eat first, so only the first unit ate once
+	if got := len(s.Units["a"]); got != 3 {
+		t.Fatalf("units before starvation = %d, want 3", got)
+	}
+	if u := s.Units["a"][1]; u.Hunger() != config.StarvationMeals - 2 {
+		t.Errorf("hunger of the first unit = %d, want %d", u.Hunger(), config.StarvationMeals - 2)
+	}
+
+	s.feedUnits("a")
+
+	if got := len(s.Units["a"]); got != 1 {
+		t.Fatalf("units after starvation = %d, want 1", got)
+	}
+	if _, ok := s.Units["a"][1]; !ok {
+		t.Error("unit that ate once died")
+	}
+	if got := rootNode(t, s, "a").Units(); len(got) != 1 {
+		t.Errorf("units on the root node = %d, want 1", len(got))
+	}
+
+	// Eating resets the hunger
+	s.Materials["a"][s.NextMaterialIDs["a"]] = model.NewMaterial(s.NextMaterialIDs["a"], "a", model.SeedMaterialType, rootNode(t, s, "a"), false)
+	s.feedUnits("a")
+	if u := s.Units["a"][1]; u.IsStarving() {
+		t.Errorf("hunger after eating = %d, want 0", u.Hunger())
+	}
+}
+
+func TestStarvedTransporterDropsMaterial(t *testing.T) {
+	s := newUpkeepState(t, map[model.UnitType]uint{model.TransportUnitType: 1}, 0)
+
+	root := rootNode(t, s, "a")
+	u := s.Units["a"][1]
+	for _, m := range sortedByID(root.OutputMaterials()) {
+		m.Reserve()
+		u.AddMaterial(m)
+		break
+	}
+
+	for range config.StarvationMeals {
+		s.feedUnits("a")
+	}
+
+	if len(s.Units["a"]) != 0 {
+		t.Fatal("transporter didn't starve")
+	}
+	if got := countMaterials(root, model.GrassMaterialType); got != 5 {
+		t.Errorf("grass on the root node = %d, want 5", got)
+	}
+	if got := countResps(s, "a", opcode.MaterialCreated); got != 1 {
+		t.Errorf("created materials = %d, want 1", got)
+	}
+}
+
+func TestAdvanceUpkeep(t *testing.T) {
+	s := newUpkeepState(t, map[model.UnitType]uint{model.IdleUnitType: 1}, 0)
+
+	ticks := int(config.UpkeepIntervalMs / s.TickMs())
+	for range ticks - 1 {
+		s.advanceUpkeep()
+	}
+	if u := s.Units["a"][1]; u.IsStarving() {
+		t.Fatal("unit starved before the upkeep")
+	}
+
+	s.advanceUpkeep()
+	if u := s.Units["a"][1]; u.Hunger() != 1 {
+		t.Errorf("hunger after the upkeep = %d, want 1", u.Hunger())
+	}
+}
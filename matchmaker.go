@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/heroiclabs/nakama-common/rtapi"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/queue"
+	"github.com/relby/achikaps/rating"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Matchmaker properties that are set by the server, clients can't change them
+const (
+	ratingProperty = "rating"
+	searchStartedProperty = "search_started"
+)
+
+// Players are matched only within the same region to have similar latency
+const regionProperty = "region"
+
+// Queue of the ticket, default queue is used if it's not set
+const queueProperty = "queue"
+
+// ticketQueue returns the queue of the matchmaker entry, the property is validated by the hook
+func ticketQueue(e runtime.MatchmakerEntry) queue.Queue {
+	v, _ := e.GetProperties()[queueProperty].(string)
+
+	q, err := queue.NewQueue(v)
+	if err != nil {
+		return queue.DefaultQueue
+	}
+
+	return q
+}
+
+func matchmakerAddHook(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, in *rtapi.Envelope) (*rtapi.Envelope, error) {
+	message, ok := in.Message.(*rtapi.Envelope_MatchmakerAdd)
+	if !ok {
+		return nil, runtime.NewError("internal server error", 13)
+	}
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return nil, runtime.NewError("internal server error", 13)
+	}
+
+	ratings, err := rating.Load(ctx, nk, []string{userID})
+	if err != nil {
+		logger.Error("can't load rating: %v", err)
+		return nil, runtime.NewError("internal server error", 13)
+	}
+	r := ratings[userID].Value
+
+	add := message.MatchmakerAdd
+	if add.NumericProperties == nil {
+		add.NumericProperties = make(map[string]float64)
+	}
+	if add.StringProperties == nil {
+		add.StringProperties = make(map[string]string)
+	}
+	add.NumericProperties[ratingProperty] = r
+	add.NumericProperties[searchStartedProperty] = float64(time.Now().Unix())
+
+	q := queue.DefaultQueue
+	if v, ok := add.StringProperties[queueProperty]; ok {
+		q, err = queue.NewQueue(v)
+		if err != nil {
+			return nil, runtime.NewError("invalid queue", 3)
+		}
+	}
+	add.StringProperties[queueProperty] = string(q)
+	c := q.Config()
+
+	query := []string{
+		fmt.Sprintf("+properties.%s:%s", queueProperty, q),
+	}
+	// Query uses the widest window, the actual window is checked in the matchmaker override
+	if c.IsRanked {
+		query = append(
+			query,
+			fmt.Sprintf("+properties.%s:>=%d", ratingProperty, int(math.Floor(r - rating.WindowMax))),
+			fmt.Sprintf("+properties.%s:<=%d", ratingProperty, int(math.Ceil(r + rating.WindowMax))),
+		)
+	}
+	if region, ok := add.StringProperties[regionProperty]; ok && region != "" {
+		if strings.ContainsFunc(region, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' }) {
+			return nil, runtime.NewError("invalid region", 3)
+		}
+
+		query = append(query, fmt.Sprintf("+properties.%s:%s", regionProperty, region))
+	}
+
+	if v, ok := add.NumericProperties[factionProperty]; ok {
+		if _, err := model.NewFaction(uint(v)); err != nil {
+			return nil, runtime.NewError("invalid faction", 3)
+		}
+	}
+
+	add.Query = strings.Join(query, " ")
+	add.MinCount = int32(c.MinCount)
+	add.MaxCount = int32(c.MaxCount)
+	add.CountMultiple = &wrapperspb.Int32Value{Value: int32(c.CountMultiple)}
+
+	return in, nil
+}
+
+// matchmakerOverride keeps ranked matches where every pair of players fits into the rating window
+// of the player that waits longer
+func matchmakerOverride(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, candidateMatches [][]runtime.MatchmakerEntry) [][]runtime.MatchmakerEntry {
+	now := float64(time.Now().Unix())
+
+	out := make([][]runtime.MatchmakerEntry, 0, len(candidateMatches))
+	for _, entries := range candidateMatches {
+		if len(entries) == 0 || !ticketQueue(entries[0]).Config().IsRanked {
+			out = append(out, entries)
+			continue
+		}
+
+		fits := true
+		for i, e1 := range entries {
+			for _, e2 := range entries[i + 1:] {
+				r1, _ := e1.GetProperties()[ratingProperty].(float64)
+				r2, _ := e2.GetProperties()[ratingProperty].(float64)
+				s1, _ := e1.GetProperties()[searchStartedProperty].(float64)
+				s2, _ := e2.GetProperties()[searchStartedProperty].(float64)
+
+				window := rating.Window(now - math.Min(s1, s2))
+				if math.Abs(r1 - r2) > window {
+					fits = false
+				}
+			}
+		}
+
+		if fits {
+			out = append(out, entries)
+		}
+	}
+
+	return out
+}
+
+func matchmakerMatchedHook(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, entries []runtime.MatchmakerEntry) (string, error) {
+	// All entries are from the same queue because of the query
+	q := ticketQueue(entries[0])
+
+	matchID, err := nk.MatchCreate(ctx, "achikaps", map[string]interface{}{"players": entries, "queue": q})
+	if err != nil {
+		return "", runtime.NewError("unable to create match", 13)
+	}
+
+	return matchID, nil
+}
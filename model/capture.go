@@ -0,0 +1,19 @@
+package model
+
+// Capture is a claim of a player on a neutral node, the node joins the graph
+// of the player when the progress reaches 1
+type Capture struct {
+	SessionID string
+	NodeID ID
+	FromNodeID ID
+	Progress float64
+}
+
+func NewCapture(sessionID string, nodeID, fromNodeID ID) *Capture {
+	return &Capture{
+		sessionID,
+		nodeID,
+		fromNodeID,
+		0,
+	}
+}
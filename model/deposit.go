@@ -0,0 +1,31 @@
+package model
+
+type DepositType uint
+
+const (
+	SandDepositType DepositType = iota + 1
+	DewDepositType
+	AmberDepositType
+)
+
+// Boosts reports if production of the node is faster when it's built on the deposit
+func (t DepositType) Boosts(name NodeName) bool {
+	switch t {
+	case SandDepositType:
+		return name == SandQuarryNodeName
+	case DewDepositType:
+		return name == WellNodeName
+	}
+
+	return false
+}
+
+// RequiredDeposit returns the deposit type the node can be built only on
+func RequiredDeposit(name NodeName) (DepositType, bool) {
+	switch name {
+	case ResinTapperNodeName:
+		return AmberDepositType, true
+	}
+
+	return 0, false
+}
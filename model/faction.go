@@ -0,0 +1,103 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/relby/achikaps/config"
+)
+
+type Faction uint
+
+const (
+	// Harvesters gather faster and move faster
+	HarvesterFaction Faction = iota + 1
+	// Soldiers build faster and have cheaper defense
+	SoldierFaction
+)
+
+// Players that don't choose a faction play harvesters
+const DefaultFaction = HarvesterFaction
+
+// Bots get factions in this order
+var Factions = []Faction{
+	HarvesterFaction,
+	SoldierFaction,
+}
+
+func NewFaction(v uint) (Faction, error) {
+	switch v := Faction(v); v {
+	case HarvesterFaction,
+	SoldierFaction:
+		return v, nil
+	}
+
+	return 0, errors.New("invalid faction")
+}
+
+// CanBuild checks if the node is not unique to another faction
+func (f Faction) CanBuild(name NodeName) bool {
+	switch name {
+	case FungusGardenNodeName:
+		return f == HarvesterFaction
+	case BarracksNodeName:
+		return f == SoldierFaction
+	}
+
+	return true
+}
+
+// UnitSpeed is the distance per second that units of the faction move
+func (f Faction) UnitSpeed() float64 {
+	switch f {
+	case HarvesterFaction:
+		return config.UnitSpeed * 1.2
+	case SoldierFaction:
+		return config.UnitSpeed * 0.9
+	default:
+		panic("unreachable")
+	}
+}
+
+// BuildTimeMs is the time builders of the faction need to build a node
+func (f Faction) BuildTimeMs() float64 {
+	switch f {
+	case HarvesterFaction:
+		return config.BuildTimeMs
+	case SoldierFaction:
+		return config.BuildTimeMs * 0.8
+	default:
+		panic("unreachable")
+	}
+}
+
+// overrideProductionData changes recipes of the faction
+func (f Faction) overrideProductionData(name NodeName, data *ProductionNodeData) {
+	switch f {
+	case HarvesterFaction:
+		switch name {
+		case GrassFieldNodeName, WellNodeName:
+			data.TimeMs *= 0.75
+		case SeedStorageNodeName:
+			data.InputMaterials[GrassMaterialType] = 2
+		}
+	case SoldierFaction:
+		switch name {
+		case ChitinPressNodeName:
+			data.TimeMs = 3_000.0
+		}
+	}
+}
+
+// overrideBuildingData changes costs of the faction
+func (f Faction) overrideBuildingData(name NodeName, data *BuildingNodeData) {
+	switch f {
+	case SoldierFaction:
+		switch name {
+		case GuardOutpostNodeName:
+			data.Materials[ChitinMaterialType] = 3
+			data.Materials[PheromoneMaterialType] = 2
+		case AmberTurretNodeName:
+			data.Materials[JuiceMaterialType] = 3
+		}
+	}
+}
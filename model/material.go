@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/relby/achikaps/assert"
 )
@@ -21,6 +22,35 @@ const (
 	AmberMaterialType
 )
 
+func NewMaterialType(v uint) (MaterialType, error) {
+	switch v := MaterialType(v); v {
+	case GrassMaterialType,
+	SandMaterialType,
+	DewMaterialType,
+	SeedMaterialType,
+	SugarMaterialType,
+	JuiceMaterialType,
+	ChitinMaterialType,
+	EggMaterialType,
+	PheromoneMaterialType,
+	AmberMaterialType:
+		return v, nil
+	}
+
+	return 0, errors.New("invalid material type")
+}
+
+// IsFood reports if units can eat the material
+func (t MaterialType) IsFood() bool {
+	switch t {
+	case SugarMaterialType,
+	SeedMaterialType:
+		return true
+	}
+
+	return false
+}
+
 type NodeData struct {
 	Node *Node
 	IsInput bool
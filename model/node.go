@@ -15,6 +15,7 @@ const (
 	TransitNodeType NodeType = iota + 1
 	ProductionNodeType
 	DefenseNodeType
+	ResearchNodeType
 )
 
 type NodeName uint
@@ -33,6 +34,15 @@ const (
 	GeneticHatcheryNodeName
 	GuardOutpostNodeName
 	AmberTurretNodeName
+	SandQuarryNodeName
+	ResinTapperNodeName
+	// Neutral nodes are placed by the map generator, they can be captured but not built
+	AncientAmberNodeName
+	AphidColonyNodeName
+	ResearchLabNodeName
+	// Unique nodes of factions
+	FungusGardenNodeName
+	BarracksNodeName
 )
 
 func NewNodeName(v uint) (NodeName, error) {
@@ -49,35 +59,80 @@ func NewNodeName(v uint) (NodeName, error) {
 	IncubatorNodeName,
 	GeneticHatcheryNodeName,
 	GuardOutpostNodeName,
-	AmberTurretNodeName:
+	AmberTurretNodeName,
+	SandQuarryNodeName,
+	ResinTapperNodeName,
+	ResearchLabNodeName,
+	FungusGardenNodeName,
+	BarracksNodeName:
 		return v, nil
 	}
 
 	return 0, errors.New("invalid node name")
 }
 
+// NodePriority values are ordered, the higher value the sooner builders and transporters work on the node
+type NodePriority uint
+
+const (
+	PausedNodePriority NodePriority = iota + 1
+	LowNodePriority
+	NormalNodePriority
+	HighNodePriority
+)
+
+func NewNodePriority(v uint) (NodePriority, error) {
+	switch v := NodePriority(v); v {
+	case PausedNodePriority,
+	LowNodePriority,
+	NormalNodePriority,
+	HighNodePriority:
+		return v, nil
+	}
+
+	return 0, errors.New("invalid node priority")
+}
+
+// Nodes start at the first level, every next level makes production faster,
+// the last level also doubles the output
+const (
+	MaxNodeLevel uint = 3
+	levelSpeedup float64 = 0.5
+	maxLevelOutputMultiplier uint = 2
+)
+
 type Node struct {
 	id       ID
 	sessionID string
+	// Faction of the owner, zero for neutral nodes
+	faction Faction
 	typ     NodeType
 	name     NodeName
 	position vec2.Vec2
 	radius   float64
 	buildProgress float64
+	level uint
+	priority NodePriority
+	// Deposit the node is built on, zero if there is none
+	deposit DepositType
 	units map[ID]*Unit
 	inputMaterials map[ID]*Material
 	outputMaterials map[ID]*Material
 }
 
-func NewNode(id ID, sessionID string, name NodeName, pos vec2.Vec2) *Node {
+func NewNode(id ID, sessionID string, faction Faction, name NodeName, pos vec2.Vec2) *Node {
 	return &Node{
 		id,
 		sessionID,
+		faction,
 		nodeNameToNodeType(name),
 		name,
 		pos,
 		config.NodeRadius,
 		0,
+		1,
+		NormalNodePriority,
+		0,
 		make(map[ID]*Unit),
 		make(map[ID]*Material),
 		make(map[ID]*Material),
@@ -104,6 +159,35 @@ func (n *Node) Radius() float64 {
 	return n.radius
 }
 
+func (n *Node) Priority() NodePriority {
+	return n.priority
+}
+
+func (n *Node) SetPriority(p NodePriority) {
+	n.priority = p
+}
+
+func (n *Node) Deposit() DepositType {
+	return n.deposit
+}
+
+func (n *Node) SetDeposit(t DepositType) {
+	n.deposit = t
+}
+
+func (n *Node) Level() uint {
+	return n.level
+}
+
+// Upgrade raises the level of the node, it has to be built again like a new node
+func (n *Node) Upgrade() {
+	assert.True(n.IsBuilt())
+	assert.True(n.level < MaxNodeLevel)
+
+	n.level += 1
+	n.buildProgress = 0
+}
+
 func (n *Node) Build(inc float64) {
 	n.buildProgress += inc
 	if n.buildProgress >= 1.0 {
@@ -200,6 +284,9 @@ func (n *Node) MarshalJSON() ([]byte, error) {
 		Position vec2.Vec2
 		Radius float64
 		BuildProgress float64
+		Level uint
+		Priority NodePriority
+		Deposit DepositType
 	}
 
 	nodeData := nodeJSON{
@@ -210,6 +297,9 @@ func (n *Node) MarshalJSON() ([]byte, error) {
 		n.position,
 		n.radius,
 		n.buildProgress,
+		n.level,
+		n.priority,
+		n.deposit,
 	}
 
 	return json.Marshal(nodeData)
@@ -217,26 +307,45 @@ func (n *Node) MarshalJSON() ([]byte, error) {
 
 type ProductionNodeData struct {
 	TimeMs float64
-	ProgressInc float64
 	InputMaterials map[MaterialType]uint
 	OutputMaterials map[MaterialType]uint
 	OutputUnits uint
 }
 
 func newProductionNodeData(timeMs float64, inputMaterials, outputMaterials map[MaterialType]uint, outputUnits uint) *ProductionNodeData {
-	ticks := timeMs * float64(config.TickRate) / 1000.0
-	progressInc := 1.0 / ticks
-
 	return &ProductionNodeData{
 		timeMs,
-		progressInc,
 		inputMaterials,
 		outputMaterials,
 		outputUnits,
 	}
 }
 
+// ProductionData returns the recipe of the node, nodes on boosting deposits and upgraded nodes produce faster
 func (n *Node) ProductionData() (*ProductionNodeData, bool) {
+	data, ok := n.baseProductionData()
+	if !ok {
+		return nil, false
+	}
+
+	n.faction.overrideProductionData(n.name, data)
+
+	if n.deposit.Boosts(n.name) {
+		data.TimeMs /= config.DepositBoost
+	}
+
+	data.TimeMs /= 1 + levelSpeedup * float64(n.level - 1)
+
+	if n.level == MaxNodeLevel {
+		for t := range data.OutputMaterials {
+			data.OutputMaterials[t] *= maxLevelOutputMultiplier
+		}
+	}
+
+	return data, ok
+}
+
+func (n *Node) baseProductionData() (*ProductionNodeData, bool) {
 	if n.typ != ProductionNodeType {
 		return nil, false
 	}
@@ -351,6 +460,68 @@ func (n *Node) ProductionData() (*ProductionNodeData, bool) {
 			nil,
 			1,
 		), true
+    case SandQuarryNodeName:
+		return newProductionNodeData(
+			5_000.0,
+			nil,
+			map[MaterialType]uint{
+				SandMaterialType: 1,
+			},
+			0,
+		), true
+    case ResinTapperNodeName:
+		return newProductionNodeData(
+			8_000.0,
+			map[MaterialType]uint{
+				DewMaterialType: 1,
+				SugarMaterialType: 1,
+			},
+			map[MaterialType]uint{
+				AmberMaterialType: 1,
+			},
+			0,
+		), true
+    case AncientAmberNodeName:
+		return newProductionNodeData(
+			4_000.0,
+			nil,
+			map[MaterialType]uint{
+				AmberMaterialType: 1,
+			},
+			0,
+		), true
+    case AphidColonyNodeName:
+		return newProductionNodeData(
+			3_000.0,
+			nil,
+			map[MaterialType]uint{
+				SugarMaterialType: 1,
+			},
+			0,
+		), true
+    case FungusGardenNodeName:
+		return newProductionNodeData(
+			4_000.0,
+			map[MaterialType]uint{
+				GrassMaterialType: 2,
+				DewMaterialType: 1,
+			},
+			map[MaterialType]uint{
+				SugarMaterialType: 1,
+				SeedMaterialType: 1,
+			},
+			0,
+		), true
+    case BarracksNodeName:
+		return newProductionNodeData(
+			8_000.0,
+			map[MaterialType]uint{
+				EggMaterialType: 1,
+				ChitinMaterialType: 1,
+			},
+			nil,
+			1,
+		), true
 	default:
 		panic("unreachable")
 	}
@@ -364,7 +535,19 @@ func newBuildingNodeData(materials map[MaterialType]uint) *BuildingNodeData {
 	return &BuildingNodeData{materials}
 }
 
+// BuildingData returns materials needed to build the node, upgrade to the next level
+// costs the base materials multiplied by the level
 func (n *Node) BuildingData() *BuildingNodeData {
+	data := n.baseBuildingData()
+	n.faction.overrideBuildingData(n.name, data)
+	for t := range data.Materials {
+		data.Materials[t] *= n.level
+	}
+
+	return data
+}
+
+func (n *Node) baseBuildingData() *BuildingNodeData {
 	switch n.name {
 	case SandTransitNodeName:
 		return newBuildingNodeData(map[MaterialType]uint{
@@ -432,11 +615,56 @@ func (n *Node) BuildingData() *BuildingNodeData {
 			JuiceMaterialType: 5,
 			AmberMaterialType: 3,
 		})
+	case SandQuarryNodeName:
+		return newBuildingNodeData(map[MaterialType]uint{
+			GrassMaterialType: 4,
+			DewMaterialType: 2,
+		})
+	case ResinTapperNodeName:
+		return newBuildingNodeData(map[MaterialType]uint{
+			GrassMaterialType: 3,
+			SeedMaterialType: 2,
+			SugarMaterialType: 2,
+		})
+	case ResearchLabNodeName:
+		return newBuildingNodeData(map[MaterialType]uint{
+			GrassMaterialType: 4,
+			SandMaterialType: 2,
+			DewMaterialType: 2,
+		})
+	case FungusGardenNodeName:
+		return newBuildingNodeData(map[MaterialType]uint{
+			GrassMaterialType: 4,
+			DewMaterialType: 2,
+			SeedMaterialType: 1,
+		})
+	case BarracksNodeName:
+		return newBuildingNodeData(map[MaterialType]uint{
+			ChitinMaterialType: 4,
+			SandMaterialType: 3,
+			EggMaterialType: 2,
+		})
 	default: 
 		panic("unreachable")
 	}
 }
 
+// Housing is the population that the node adds to the cap of the player,
+// node that is being upgraded keeps it
+func (n *Node) Housing() int {
+	if !n.IsBuilt() && n.level == 1 {
+		return 0
+	}
+
+	switch n.name {
+	case IncubatorNodeName,
+		BarracksNodeName:
+		return config.HousingPopulation
+	}
+
+	return 0
+}
+
 func nodeNameToNodeType(name NodeName) NodeType {
 	switch name {
 	case SandTransitNodeName:
@@ -450,11 +678,19 @@ func nodeNameToNodeType(name NodeName) NodeType {
 		EggFarmNodeName,
 		PheromoneMineNodeName,
 		IncubatorNodeName,
-		GeneticHatcheryNodeName:
+		GeneticHatcheryNodeName,
+		SandQuarryNodeName,
+		ResinTapperNodeName,
+		AncientAmberNodeName,
+		AphidColonyNodeName,
+		FungusGardenNodeName,
+		BarracksNodeName:
 		return ProductionNodeType
 	case GuardOutpostNodeName,
 		AmberTurretNodeName:
 		return DefenseNodeType
+	case ResearchLabNodeName:
+		return ResearchNodeType
 	default:
 		panic("unreachable")
 	}
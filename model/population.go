@@ -0,0 +1,14 @@
+package model
+
+// Population is the number of units of a player and the cap that comes from housing nodes
+type Population struct {
+	Current int
+	Max int
+}
+
+func NewPopulation(current, max int) *Population {
+	return &Population{
+		current,
+		max,
+	}
+}
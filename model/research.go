@@ -0,0 +1,19 @@
+package model
+
+// Research of a tech by a player, materials are brought to the node
+// and the progress starts when all of them are there
+type Research struct {
+	TechID ID
+	NodeID ID
+	IsStarted bool
+	Progress float64
+}
+
+func NewResearch(techID, nodeID ID) *Research {
+	return &Research{
+		techID,
+		nodeID,
+		false,
+		0,
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+// Trade is an offer of one player to another, materials are given from the node
+// and received materials are delivered to the same node
+type Trade struct {
+	ID ID
+	FromSessionID string
+	ToSessionID string
+	FromNodeID ID
+	GiveType MaterialType
+	GiveCount int
+	WantType MaterialType
+	WantCount int
+}
+
+func NewTrade(id ID, fromSessionID, toSessionID string, fromNodeID ID, giveType MaterialType, giveCount int, wantType MaterialType, wantCount int) *Trade {
+	return &Trade{
+		id,
+		fromSessionID,
+		toSessionID,
+		fromNodeID,
+		giveType,
+		giveCount,
+		wantType,
+		wantCount,
+	}
+}
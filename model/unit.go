@@ -6,7 +6,6 @@ import (
 
 	"github.com/gammazero/deque"
 	"github.com/relby/achikaps/assert"
-	"github.com/relby/achikaps/config"
 )
 
 type UnitType uint
@@ -37,6 +36,9 @@ type Unit struct {
 	node *Node
 	material *Material
 	actions *deque.Deque[*UnitAction]
+	command *UnitCommand
+	// Meals missed in a row
+	hunger uint
 }
 
 func NewUnit(id ID, sessionID string, typ UnitType, n *Node) *Unit {
@@ -47,6 +49,8 @@ func NewUnit(id ID, sessionID string, typ UnitType, n *Node) *Unit {
 		nil,
 		nil,
 		&deque.Deque[*UnitAction]{},
+		nil,
+		0,
 	}
 	
 	n.AddUnit(u)
@@ -58,6 +62,10 @@ func (u *Unit) ID() ID {
 	return u.id
 }
 
+func (u *Unit) SessionID() string {
+	return u.sessionID
+}
+
 func (u *Unit) Type() UnitType {
 	return u.typ
 }
@@ -68,39 +76,53 @@ func (u *Unit) SetType(t UnitType) {
 		return
 	}
 	
-	// If we change the type of the transport unit
-	// we should ensure that material is not lost
-	switch u.typ {
-		case TransportUnitType:
-			if u.material == nil {
-				break
-			}
-			assert.Nil(u.material.NodeData())
+	// Player's order was given to a unit of the old type
+	u.command = nil
+	u.resetActions()
+
+	u.typ = t
+}
 
-			if u.node != nil {
-				u.node.AddOutputMaterial(u.material)
-			} else {
-				// In here unit is moving
-				assert.NotEquals(u.actions.Len(), 0)
+// resetActions drops every planned action except the move that the unit is
+// currently doing, so that reserved and carried materials are not lost
+func (u *Unit) resetActions() {
+	// If we reset the transport unit
+	// we should ensure that material is not lost
+	if u.material != nil {
+		assert.Nil(u.material.NodeData())
+
+		if u.node != nil {
+			u.node.AddOutputMaterial(u.material)
+		} else {
+			// In here unit is moving
+			assert.NotEquals(u.actions.Len(), 0)
+
+			movingAction := u.actions.Front()
+			assert.Equals(movingAction.Type, MovingUnitActionType)
+			
+			movingActionData, ok := movingAction.Data.(*MovingUnitActionData)
+			assert.True(ok)
 
-				movingAction := u.actions.Front()
-				assert.Equals(movingAction.Type, MovingUnitActionType)
-				
-				movingActionData, ok := movingAction.Data.(*MovingUnitActionData)
-				assert.True(ok)
+			movingActionData.FromNode.AddOutputMaterial(u.material)
+		}
 
-				movingActionData.FromNode.AddOutputMaterial(u.material)
-			}
+		u.material.UnReserve()
+		u.material = nil
 	}
 
 	for i := range u.actions.Len() {
 		a := u.actions.At(i)
-		if a.Type == ProductionUnitActionType {
+		switch a.Type {
+		case ProductionUnitActionType:
 			uaData, ok := a.Data.(*ProductionUnitActionData)
 			assert.True(ok)
 			for _, m := range uaData.InputMaterials {
 				m.UnReserve()
 			}
+		case TakeMaterialUnitActionType:
+			uaData, ok := a.Data.(*TakeMaterialUnitActionData)
+			assert.True(ok)
+			uaData.Material.UnReserve()
 		}
 	}
 	
@@ -113,8 +135,54 @@ func (u *Unit) SetType(t UnitType) {
 			u.actions.PushBack(a)
 		}
 	}
+}
 
-	u.typ = t
+func (u *Unit) Command() *UnitCommand {
+	return u.command
+}
+
+// SetCommand pins the unit to the player's order, automatic polling is
+// skipped until the command is cleared
+func (u *Unit) SetCommand(c *UnitCommand) {
+	u.resetActions()
+	u.command = c
+}
+
+func (u *Unit) ClearCommand() {
+	if u.command == nil {
+		return
+	}
+
+	u.resetActions()
+	u.command = nil
+}
+
+func (u *Unit) Hunger() uint {
+	return u.hunger
+}
+
+func (u *Unit) IsStarving() bool {
+	return u.hunger > 0
+}
+
+func (u *Unit) Feed() {
+	u.hunger = 0
+}
+
+func (u *Unit) Starve() {
+	u.hunger += 1
+}
+
+// Kill drops everything the unit is doing and removes it from the node,
+// carried material is returned to the node it was taken from
+func (u *Unit) Kill() {
+	u.command = nil
+	u.resetActions()
+	u.actions.Clear()
+
+	if u.node != nil {
+		u.node.RemoveUnit(u)
+	}
 }
 
 func (u *Unit) Node() *Node {
@@ -163,6 +231,8 @@ func (u *Unit) MarshalJSON() ([]byte, error) {
 			Node    *Node
 			Material *Material
 			Actions  []*UnitAction
+			Command *UnitCommand
+			Hunger uint
 		}{
 			u.id,
 			u.sessionID,
@@ -170,6 +240,8 @@ func (u *Unit) MarshalJSON() ([]byte, error) {
 			u.node,
 			u.material,
 			actions,
+			u.command,
+			u.hunger,
 		}
 	} else {
 		unitData = struct {
@@ -178,12 +250,16 @@ func (u *Unit) MarshalJSON() ([]byte, error) {
 			Type    UnitType
 			Node    *Node
 			Actions  []*UnitAction
+			Command *UnitCommand
+			Hunger uint
 		}{
 			u.id,
 			u.sessionID,
 			u.typ,
 			u.node,
 			actions,
+			u.command,
+			u.hunger,
 		}
 	}
 
@@ -211,6 +287,7 @@ func newUnitAction(typ UnitActionType, data any) *UnitAction {
 }
 
 type MovingUnitActionData struct {
+	// Distance per second
 	Speed float64
 	TimeMs float64
 	FromNode *Node
@@ -219,9 +296,8 @@ type MovingUnitActionData struct {
 }
 
 func NewMovingUnitAction(speed float64, fromNode, toNode *Node) *UnitAction {
-	ticks := 1.0 / (speed / fromNode.DistanceTo(toNode))
+	timeMs := fromNode.DistanceTo(toNode) / speed * 1000.0
 
-	timeMs := ticks * (1000.0 / float64(config.TickRate))
 	return newUnitAction(
 		MovingUnitActionType,
 		&MovingUnitActionData{speed, timeMs, fromNode, toNode, 0},
@@ -260,4 +336,63 @@ func NewTakeMaterialUnitAction(m *Material) *UnitAction {
 
 func NewDropMaterialUnitAction() *UnitAction {
 	return newUnitAction(DropMaterialUnitActionType, nil)
+}
+
+type UnitCommandType uint
+
+const (
+	MoveUnitCommandType UnitCommandType = iota + 1
+	WorkUnitCommandType
+	HaulUnitCommandType
+)
+
+func NewUnitCommandType(v uint) (UnitCommandType, error) {
+	switch v := UnitCommandType(v); v {
+		case MoveUnitCommandType,
+			WorkUnitCommandType,
+			HaulUnitCommandType:
+			return v, nil
+	}
+	
+	return 0, errors.New("invalid unit command type")
+}
+
+// UnitCommand is a standing order given by a player to a unit
+type UnitCommand struct {
+	Type UnitCommandType
+	Data any
+}
+
+func newUnitCommand(typ UnitCommandType, data any) *UnitCommand {
+	return &UnitCommand{typ, data}
+}
+
+type MoveUnitCommandData struct {
+	Node *Node
+}
+
+// NewMoveUnitCommand makes the unit go to the node and stay there
+func NewMoveUnitCommand(n *Node) *UnitCommand {
+	return newUnitCommand(MoveUnitCommandType, &MoveUnitCommandData{n})
+}
+
+type WorkUnitCommandData struct {
+	Node *Node
+}
+
+// NewWorkUnitCommand pins the production unit to the production node
+func NewWorkUnitCommand(n *Node) *UnitCommand {
+	return newUnitCommand(WorkUnitCommandType, &WorkUnitCommandData{n})
+}
+
+type HaulUnitCommandData struct {
+	FromNode *Node
+	ToNode *Node
+	// Zero value means any material type
+	MaterialType MaterialType
+}
+
+// NewHaulUnitCommand makes the transport unit carry materials from one node to another
+func NewHaulUnitCommand(fromNode, toNode *Node, matType MaterialType) *UnitCommand {
+	return newUnitCommand(HaulUnitCommandType, &HaulUnitCommandData{fromNode, toNode, matType})
 }
\ No newline at end of file
@@ -0,0 +1,33 @@
+package opcode
+
+import (
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// Message is sent on behalf of the player by the server, it's handled like a client message
+type Message struct {
+	sessionID string
+	opCode OpCode
+	data []byte
+}
+
+func NewMessage(sessionID string, opCode OpCode, data []byte) *Message {
+	return &Message{
+		sessionID,
+		opCode,
+		data,
+	}
+}
+
+func (m *Message) GetHidden() bool { return true }
+func (m *Message) GetPersistence() bool { return false }
+func (m *Message) GetUsername() string { return m.sessionID }
+func (m *Message) GetStatus() string { return "" }
+func (m *Message) GetReason() runtime.PresenceReason { return runtime.PresenceReasonUnknown }
+func (m *Message) GetUserId() string { return "" }
+func (m *Message) GetSessionId() string { return m.sessionID }
+func (m *Message) GetNodeId() string { return "" }
+func (m *Message) GetOpCode() int64 { return int64(m.opCode) }
+func (m *Message) GetData() []byte { return m.data }
+func (m *Message) GetReliable() bool { return true }
+func (m *Message) GetReceiveTime() int64 { return 0 }
@@ -3,7 +3,10 @@ package opcode
 import (
 	"errors"
 
+	"github.com/relby/achikaps/game_map"
 	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/rating"
+	"github.com/relby/achikaps/tech_tree"
 	"github.com/relby/achikaps/win_condition"
 )
 
@@ -19,7 +22,27 @@ func NewOpCode(v int64) (OpCode, error) {
 		NodeBuilt,
 		MaterialDestroyed,
 		MaterialCreated,
-		UnitCreated:
+		UnitCreated,
+		UnitCommand,
+		ClearUnitCommand,
+		BulkChangeUnitType,
+		SetRoleQuota,
+		UnitTypeChanged,
+		SetNodePriority,
+		Pause,
+		Resume,
+		SendMaterials,
+		OfferTrade,
+		AcceptTrade,
+		RejectTrade,
+		ClaimNode,
+		NodeCaptured,
+		UpgradeNode,
+		StartResearch,
+		ResearchCompleted,
+		UnitHungerChanged,
+		PopulationStats,
+		CancelClaim:
 		return v, nil
 	}
 
@@ -36,6 +59,26 @@ const (
 	MaterialDestroyed
 	MaterialCreated
 	UnitCreated
+	UnitCommand
+	ClearUnitCommand
+	BulkChangeUnitType
+	SetRoleQuota
+	UnitTypeChanged
+	SetNodePriority
+	Pause
+	Resume
+	SendMaterials
+	OfferTrade
+	AcceptTrade
+	RejectTrade
+	ClaimNode
+	NodeCaptured
+	UpgradeNode
+	StartResearch
+	ResearchCompleted
+	UnitHungerChanged
+	PopulationStats
+	CancelClaim
 )
 
 type RespWithOpCode struct {
@@ -54,53 +97,163 @@ type InitialStateResp struct {
 	Units map[string]map[model.ID]*model.Unit
 	Materials map[string]map[model.ID]*model.Material
 	WinCondition *win_condition.WinCondition
+	// Teams of players by their session IDs
+	Teams map[string]uint
+	Factions map[string]model.Faction
+	Map *game_map.Map
+	NeutralNodes map[model.ID]*model.Node
+	// Claims of players on neutral nodes by node IDs and session IDs
+	Captures map[model.ID]map[string]*model.Capture
+	TechTree *tech_tree.TechTree
+	// Researched techs by session IDs
+	Techs map[string]map[model.ID]bool
+	// Researches in progress by session IDs
+	Researches map[string]*model.Research
+	// Progress to the next meal of all units
+	UpkeepProgress float64
+	Populations map[string]*model.Population
+}
+
+// TeamEvent is embedded in events about the player, team is set when the event is sent
+type TeamEvent struct {
+	Team uint
+}
+
+func (e *TeamEvent) SetTeam(team uint) {
+	e.Team = team
 }
 
 type UnitActionExecuteResp struct {
 	Unit *model.Unit
 	UnitAction *model.UnitAction
+	TeamEvent
 }
 
 func NewUnitActionExecuteResp(u *model.Unit, a *model.UnitAction) *UnitActionExecuteResp {
-	return &UnitActionExecuteResp{u, a}
+	return &UnitActionExecuteResp{u, a, TeamEvent{}}
 }
 
 type WinResp struct {
 	SessionID string
+	// Every player of the team wins
+	Team uint
+	// Rating changes of players by their session IDs, empty if the match is not rated
+	Ratings map[string]*rating.Change
 }
 
-func NewWinResp(sID string) *WinResp {
-	return &WinResp{sID}
+func NewWinResp(sID string, team uint, ratings map[string]*rating.Change) *WinResp {
+	return &WinResp{sID, team, ratings}
 }
 
 type NodeBuiltResp struct {
 	Node *model.Node
+	TeamEvent
 }
 
 func NewNodeBuiltResp(n *model.Node) *NodeBuiltResp {
-	return &NodeBuiltResp{n}
+	return &NodeBuiltResp{n, TeamEvent{}}
 }
 
 type MaterialDestroyedResp struct {
 	Material *model.Material
+	TeamEvent
 }
 
 func NewMaterialDestroyedResp(m *model.Material) *MaterialDestroyedResp {
-	return &MaterialDestroyedResp{m}
+	return &MaterialDestroyedResp{m, TeamEvent{}}
 }
 
 type MaterialCreatedResp struct {
 	Material *model.Material
+	TeamEvent
 }
 
 func NewMaterialCreatedResp(m *model.Material) *MaterialCreatedResp {
-	return &MaterialCreatedResp{m}
+	return &MaterialCreatedResp{m, TeamEvent{}}
 }
 
 type UnitCreatedResp struct {
 	Unit *model.Unit
+	TeamEvent
 }
 
 func NewUnitCreatedResp(u *model.Unit) *UnitCreatedResp {
-	return &UnitCreatedResp{u}
-}
\ No newline at end of file
+	return &UnitCreatedResp{u, TeamEvent{}}
+}
+
+type UnitTypeChangedResp struct {
+	Unit *model.Unit
+	TeamEvent
+}
+
+func NewUnitTypeChangedResp(u *model.Unit) *UnitTypeChangedResp {
+	return &UnitTypeChangedResp{u, TeamEvent{}}
+}
+
+type PauseResp struct {
+	// Empty if the match is paused by an admin
+	SessionID string
+	PausesLeft uint
+	// Match is resumed automatically after this time, 0 if there is no timeout
+	TimeoutMs uint
+}
+
+func NewPauseResp(sessionID string, pausesLeft, timeoutMs uint) *PauseResp {
+	return &PauseResp{sessionID, pausesLeft, timeoutMs}
+}
+
+type ResumeResp struct {
+	// Empty if the match is resumed by an admin or on timeout
+	SessionID string
+	IsTimeout bool
+}
+
+func NewResumeResp(sessionID string, isTimeout bool) *ResumeResp {
+	return &ResumeResp{sessionID, isTimeout}
+}
+
+type NodeCapturedResp struct {
+	SessionID string
+	// ID of the neutral node, it's removed from neutral nodes
+	NeutralNodeID model.ID
+	// Node in the graph of the player
+	Node *model.Node
+	FromNodeID model.ID
+}
+
+func NewNodeCapturedResp(sessionID string, neutralNodeID model.ID, n *model.Node, fromNodeID model.ID) *NodeCapturedResp {
+	return &NodeCapturedResp{sessionID, neutralNodeID, n, fromNodeID}
+}
+
+type ResearchCompletedResp struct {
+	SessionID string
+	TechID model.ID
+	// Nodes that can be built now
+	Unlocks []model.NodeName
+	TeamEvent
+}
+
+func NewResearchCompletedResp(sessionID string, techID model.ID, unlocks []model.NodeName) *ResearchCompletedResp {
+	return &ResearchCompletedResp{sessionID, techID, unlocks, TeamEvent{}}
+}
+
+type UnitHungerChangedResp struct {
+	Unit *model.Unit
+	// Unit starved to death, it's removed from units
+	IsDead bool
+	TeamEvent
+}
+
+func NewUnitHungerChangedResp(u *model.Unit, isDead bool) *UnitHungerChangedResp {
+	return &UnitHungerChangedResp{u, isDead, TeamEvent{}}
+}
+
+type PopulationStatsResp struct {
+	SessionID string
+	Population *model.Population
+	TeamEvent
+}
+
+func NewPopulationStatsResp(sessionID string, p *model.Population) *PopulationStatsResp {
+	return &PopulationStatsResp{sessionID, p, TeamEvent{}}
+}
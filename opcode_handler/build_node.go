@@ -54,8 +54,8 @@ func BuildNodeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData,
 		return fmt.Errorf("can't marshal resp: %w", err)
 	}
 
-	if err := dispatcher.BroadcastMessage(int64(opcode.BuildNode), respBytes, nil, state.Presences[sessionID], true); err != nil {
-		return fmt.Errorf("can't broadcast message: %w", err)
+	if err := sendResp(dispatcher, opcode.BuildNode, respBytes, sessionID, state); err != nil {
+		return err
 	}
 
 	return nil
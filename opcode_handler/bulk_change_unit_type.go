@@ -0,0 +1,106 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type bulkChangeUnitTypeSelectReq struct {
+	Type uint
+	NodeID uint
+	Count uint
+}
+
+type bulkChangeUnitTypeReq struct {
+	// Either IDs or Select should be set
+	IDs []uint
+	Select *bulkChangeUnitTypeSelectReq
+	Type uint
+}
+
+type bulkChangeUnitTypeResp struct {
+	Units []*model.Unit
+	// How many units were requested by Select but were not found
+	Missing uint
+}
+
+func BulkChangeUnitTypeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req bulkChangeUnitTypeReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+	}
+
+	typ, err := model.NewUnitType(req.Type)	
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid Type: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+	}
+	
+	if (req.IDs == nil) == (req.Select == nil) {
+		return sendErrorResp(fmt.Errorf("exactly one of IDs or Select should be set"), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+	}
+
+	resp := &bulkChangeUnitTypeResp{}
+
+	ids := req.IDs
+	if req.Select != nil {
+		nodeID, err := model.NewID(req.Select.NodeID)
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid Select.NodeID: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+		}
+
+		fromTyp, err := model.NewUnitType(req.Select.Type)	
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid Select.Type: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+		}
+
+		units, err := state.SelectUnits(sessionID, nodeID, fromTyp, int(req.Select.Count))
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("can't select units: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+		}
+		
+		ids = make([]uint, 0, len(units))
+		for _, u := range units {
+			ids = append(ids, uint(u.ID()))
+		}
+		resp.Missing = req.Select.Count - uint(len(units))
+	}
+	
+	seen := make(map[model.ID]struct{}, len(ids))
+	unitIDs := make([]model.ID, 0, len(ids))
+	for _, v := range ids {
+		id, err := model.NewID(v)
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid IDs: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		unitIDs = append(unitIDs, id)
+	}
+
+	resp.Units, err = state.ChangeUnitTypes(sessionID, unitIDs, typ)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't change unit types: %w", err), dispatcher, opcode.BulkChangeUnitType, sessionID, state)
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.BulkChangeUnitType, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
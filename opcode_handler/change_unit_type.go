@@ -52,8 +52,8 @@ func ChangeUnitTypeHandler(dispatcher runtime.MatchDispatcher, msg runtime.Match
 		return fmt.Errorf("can't marshal resp: %w", err)
 	}
 
-	if err := dispatcher.BroadcastMessage(int64(opcode.ChangeUnitType), respBytes, nil, state.Presences[sessionID], true); err != nil {
-		return fmt.Errorf("can't broadcast message: %w", err)
+	if err := sendResp(dispatcher, opcode.ChangeUnitType, respBytes, sessionID, state); err != nil {
+		return err
 	}
 
 	return nil
@@ -0,0 +1,89 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type claimNodeReq struct {
+	FromNodeID uint
+	NodeID uint
+}
+
+type claimNodeResp struct {
+	Capture *model.Capture
+}
+
+func ClaimNodeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+
+	var req claimNodeReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.ClaimNode, sessionID, state)
+	}
+
+	fromNodeID, err := model.NewID(req.FromNodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid FromNodeID: %w", err), dispatcher, opcode.ClaimNode, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.ClaimNode, sessionID, state)
+	}
+
+	c, err := state.ClaimNode(sessionID, fromNodeID, nodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't claim node: %w", err), dispatcher, opcode.ClaimNode, sessionID, state)
+	}
+
+	respBytes, err := json.Marshal(&claimNodeResp{c})
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.ClaimNode, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type cancelClaimReq struct {
+	NodeID uint
+}
+
+func CancelClaimHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+
+	var req cancelClaimReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.CancelClaim, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.CancelClaim, sessionID, state)
+	}
+
+	c, err := state.CancelClaim(sessionID, nodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't cancel claim: %w", err), dispatcher, opcode.CancelClaim, sessionID, state)
+	}
+
+	respBytes, err := json.Marshal(&claimNodeResp{c})
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.CancelClaim, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
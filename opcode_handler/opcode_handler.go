@@ -2,6 +2,7 @@ package opcode_handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/heroiclabs/nakama-common/runtime"
@@ -15,19 +16,92 @@ type Handler func(runtime.MatchDispatcher, runtime.MatchData, *match_state.State
 var Handlers = map[opcode.OpCode]Handler{
 	opcode.BuildNode: BuildNodeHandler,
 	opcode.ChangeUnitType: ChangeUnitTypeHandler,
+	opcode.UnitCommand: UnitCommandHandler,
+	opcode.ClearUnitCommand: ClearUnitCommandHandler,
+	opcode.BulkChangeUnitType: BulkChangeUnitTypeHandler,
+	opcode.SetRoleQuota: SetRoleQuotaHandler,
+	opcode.SetNodePriority: SetNodePriorityHandler,
+	opcode.Pause: PauseHandler,
+	opcode.Resume: ResumeHandler,
+	opcode.SendMaterials: SendMaterialsHandler,
+	opcode.OfferTrade: OfferTradeHandler,
+	opcode.AcceptTrade: AcceptTradeHandler,
+	opcode.RejectTrade: RejectTradeHandler,
+	opcode.ClaimNode: ClaimNodeHandler,
+	opcode.CancelClaim: CancelClaimHandler,
+	opcode.UpgradeNode: UpgradeNodeHandler,
+	opcode.StartResearch: StartResearchHandler,
 }
 
 type okResp struct{}
 
+// ErrorCode lets clients handle errors without parsing their messages,
+// errors that clients don't need to handle have zero code
+type ErrorCode uint
+
+const (
+	NodeLockedErrorCode ErrorCode = iota + 1
+)
+
+var errorCodes = map[error]ErrorCode{
+	match_state.ErrNodeLocked: NodeLockedErrorCode,
+}
+
+func errorCode(err error) ErrorCode {
+	for target, code := range errorCodes {
+		if errors.Is(err, target) {
+			return code
+		}
+	}
+
+	return 0
+}
+
 type errorResp struct {
 	Error string
+	Code ErrorCode
 }
 
 func sendOkResp(dispatcher runtime.MatchDispatcher, opCode opcode.OpCode, sessionID string, state *match_state.State) error {
 	resp, err := json.Marshal(okResp{})
 	assert.NoError(err)
 
-	if err := dispatcher.BroadcastMessage(int64(opCode), resp, nil, state.Presences[sessionID], true); err != nil {
+	// Bots don't have presences
+	p, ok := state.Presences[sessionID]
+	if !ok {
+		return nil
+	}
+
+	if err := dispatcher.BroadcastMessage(int64(opCode), resp, nil, p, true); err != nil {
+		return fmt.Errorf("can't broadcast message: %w", err)
+	}
+
+	return nil
+}
+
+// sendResp sends the response to everyone who sees updates of the player
+func sendResp(dispatcher runtime.MatchDispatcher, opCode opcode.OpCode, resp []byte, sessionID string, state *match_state.State) error {
+	presences, ok := state.Recipients(sessionID)
+	if !ok {
+		return nil
+	}
+
+	if err := dispatcher.BroadcastMessage(int64(opCode), resp, presences, state.Presences[sessionID], true); err != nil {
+		return fmt.Errorf("can't broadcast message: %w", err)
+	}
+
+	return nil
+}
+
+// sendPlayerResp sends the response only to the player
+func sendPlayerResp(dispatcher runtime.MatchDispatcher, opCode opcode.OpCode, resp []byte, sessionID string, state *match_state.State) error {
+	// Bots don't have presences
+	p, ok := state.Presences[sessionID]
+	if !ok {
+		return nil
+	}
+
+	if err := dispatcher.BroadcastMessage(int64(opCode), resp, []runtime.Presence{p}, p, true); err != nil {
 		return fmt.Errorf("can't broadcast message: %w", err)
 	}
 
@@ -35,10 +109,16 @@ func sendOkResp(dispatcher runtime.MatchDispatcher, opCode opcode.OpCode, sessio
 }
 
 func sendErrorResp(err error, dispatcher runtime.MatchDispatcher, opCode opcode.OpCode, sessionID string, state *match_state.State) error {
-	resp, err := json.Marshal(errorResp{Error: err.Error()})
+	resp, err := json.Marshal(errorResp{Error: err.Error(), Code: errorCode(err)})
 	assert.NoError(err)
 
-	if err := dispatcher.BroadcastMessage(int64(opCode), resp, []runtime.Presence{state.Presences[sessionID]}, state.Presences[sessionID], true); err != nil {
+	// Bots don't have presences
+	p, ok := state.Presences[sessionID]
+	if !ok {
+		return nil
+	}
+
+	if err := dispatcher.BroadcastMessage(int64(opCode), resp, []runtime.Presence{p}, p, true); err != nil {
 		return fmt.Errorf("can't broadcast message: %w", err)
 	}
 
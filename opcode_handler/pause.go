@@ -0,0 +1,31 @@
+package opcode_handler
+
+import (
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/opcode"
+)
+
+// PauseHandler pauses the match, the pause event is broadcasted to everyone
+func PauseHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+
+	if err := state.Pause(sessionID); err != nil {
+		return sendErrorResp(fmt.Errorf("can't pause match: %w", err), dispatcher, opcode.Pause, sessionID, state)
+	}
+
+	return nil
+}
+
+// ResumeHandler resumes the match, the resume event is broadcasted to everyone
+func ResumeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+
+	if err := state.Resume(sessionID); err != nil {
+		return sendErrorResp(fmt.Errorf("can't resume match: %w", err), dispatcher, opcode.Resume, sessionID, state)
+	}
+
+	return nil
+}
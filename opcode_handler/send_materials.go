@@ -0,0 +1,63 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type sendMaterialsReq struct {
+	ToSessionID string
+	FromNodeID uint
+	MaterialType uint
+	Count int
+}
+
+type sendMaterialsResp struct {
+	ToSessionID string
+	Materials []*model.Material
+}
+
+func SendMaterialsHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req sendMaterialsReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.SendMaterials, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.FromNodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid FromNodeID: %w", err), dispatcher, opcode.SendMaterials, sessionID, state)
+	}
+
+	typ, err := model.NewMaterialType(req.MaterialType)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid MaterialType: %w", err), dispatcher, opcode.SendMaterials, sessionID, state)
+	}
+	
+	materials, err := state.SendMaterials(sessionID, req.ToSessionID, nodeID, typ, req.Count)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't send materials: %w", err), dispatcher, opcode.SendMaterials, sessionID, state)
+	}
+	
+	resp := &sendMaterialsResp{
+		ToSessionID: req.ToSessionID,
+		Materials: materials,
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.SendMaterials, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
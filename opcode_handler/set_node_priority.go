@@ -0,0 +1,59 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type setNodePriorityReq struct {
+	NodeID uint
+	Priority uint
+}
+
+type setNodePriorityResp struct {
+	Node *model.Node
+}
+
+func SetNodePriorityHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req setNodePriorityReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.SetNodePriority, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.SetNodePriority, sessionID, state)
+	}
+
+	priority, err := model.NewNodePriority(req.Priority)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid Priority: %w", err), dispatcher, opcode.SetNodePriority, sessionID, state)
+	}
+	
+	n, err := state.SetNodePriority(sessionID, nodeID, priority)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't set node priority: %w", err), dispatcher, opcode.SetNodePriority, sessionID, state)
+	}
+	
+	resp := &setNodePriorityResp{
+		Node: n,
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.SetNodePriority, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type setRoleQuotaReq struct {
+	// Unit type to percents
+	Quota map[uint]uint
+}
+
+type setRoleQuotaResp struct {
+	Quota map[model.UnitType]uint
+}
+
+func SetRoleQuotaHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req setRoleQuotaReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.SetRoleQuota, sessionID, state)
+	}
+
+	quota := make(map[model.UnitType]uint, len(req.Quota))
+	for k, v := range req.Quota {
+		typ, err := model.NewUnitType(k)	
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid Quota type: %w", err), dispatcher, opcode.SetRoleQuota, sessionID, state)
+		}
+
+		quota[typ] = v
+	}
+	
+	if err := state.SetRoleQuota(sessionID, quota); err != nil {
+		return sendErrorResp(fmt.Errorf("can't set role quota: %w", err), dispatcher, opcode.SetRoleQuota, sessionID, state)
+	}
+	
+	resp := &setRoleQuotaResp{
+		Quota: quota,
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendPlayerResp(dispatcher, opcode.SetRoleQuota, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
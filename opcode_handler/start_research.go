@@ -0,0 +1,55 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type startResearchReq struct {
+	TechID uint
+	NodeID uint
+}
+
+type startResearchResp struct {
+	Research *model.Research
+}
+
+func StartResearchHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+
+	var req startResearchReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.StartResearch, sessionID, state)
+	}
+
+	techID, err := model.NewID(req.TechID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid TechID: %w", err), dispatcher, opcode.StartResearch, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.StartResearch, sessionID, state)
+	}
+
+	r, err := state.StartResearch(sessionID, techID, nodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't start research: %w", err), dispatcher, opcode.StartResearch, sessionID, state)
+	}
+
+	respBytes, err := json.Marshal(&startResearchResp{r})
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.StartResearch, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
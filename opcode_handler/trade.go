@@ -0,0 +1,142 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type offerTradeReq struct {
+	ToSessionID string
+	FromNodeID uint
+	GiveType uint
+	GiveCount int
+	WantType uint
+	WantCount int
+}
+
+type acceptTradeReq struct {
+	TradeID uint
+	NodeID uint
+}
+
+type rejectTradeReq struct {
+	TradeID uint
+}
+
+type tradeResp struct {
+	Trade *model.Trade
+	// Player that sent the request
+	SessionID string
+}
+
+// sendTradeResp sends the response to both sides of the trade and spectators
+func sendTradeResp(dispatcher runtime.MatchDispatcher, opCode opcode.OpCode, t *model.Trade, sessionID string, state *match_state.State) error {
+	respBytes, err := json.Marshal(&tradeResp{t, sessionID})
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	presences := make([]runtime.Presence, 0, 2 + len(state.Spectators))
+	for _, id := range []string{t.FromSessionID, t.ToSessionID} {
+		if p, ok := state.Presences[id]; ok {
+			presences = append(presences, p)
+		}
+	}
+	for _, p := range state.Spectators {
+		presences = append(presences, p)
+	}
+
+	// Empty presences would broadcast to everyone
+	if len(presences) == 0 {
+		return nil
+	}
+
+	if err := dispatcher.BroadcastMessage(int64(opCode), respBytes, presences, state.Presences[sessionID], true); err != nil {
+		return fmt.Errorf("can't broadcast message: %w", err)
+	}
+
+	return nil
+}
+
+func OfferTradeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req offerTradeReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.OfferTrade, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.FromNodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid FromNodeID: %w", err), dispatcher, opcode.OfferTrade, sessionID, state)
+	}
+
+	giveType, err := model.NewMaterialType(req.GiveType)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid GiveType: %w", err), dispatcher, opcode.OfferTrade, sessionID, state)
+	}
+
+	wantType, err := model.NewMaterialType(req.WantType)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid WantType: %w", err), dispatcher, opcode.OfferTrade, sessionID, state)
+	}
+	
+	t, err := state.OfferTrade(sessionID, req.ToSessionID, nodeID, giveType, req.GiveCount, wantType, req.WantCount)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't offer trade: %w", err), dispatcher, opcode.OfferTrade, sessionID, state)
+	}
+	
+	return sendTradeResp(dispatcher, opcode.OfferTrade, t, sessionID, state)
+}
+
+func AcceptTradeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req acceptTradeReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.AcceptTrade, sessionID, state)
+	}
+
+	tradeID, err := model.NewID(req.TradeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid TradeID: %w", err), dispatcher, opcode.AcceptTrade, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.AcceptTrade, sessionID, state)
+	}
+	
+	t, err := state.AcceptTrade(sessionID, tradeID, nodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't accept trade: %w", err), dispatcher, opcode.AcceptTrade, sessionID, state)
+	}
+	
+	return sendTradeResp(dispatcher, opcode.AcceptTrade, t, sessionID, state)
+}
+
+func RejectTradeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req rejectTradeReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.RejectTrade, sessionID, state)
+	}
+
+	tradeID, err := model.NewID(req.TradeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid TradeID: %w", err), dispatcher, opcode.RejectTrade, sessionID, state)
+	}
+	
+	t, err := state.RejectTrade(sessionID, tradeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't reject trade: %w", err), dispatcher, opcode.RejectTrade, sessionID, state)
+	}
+	
+	return sendTradeResp(dispatcher, opcode.RejectTrade, t, sessionID, state)
+}
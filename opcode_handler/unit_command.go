@@ -0,0 +1,164 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/graph"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type unitCommandReq struct {
+	UnitID uint
+	Type uint
+	// Move and Work
+	NodeID uint
+	// Haul
+	FromNodeID uint
+	ToNodeID uint
+	MaterialType uint
+}
+
+type unitCommandResp struct {
+	Unit *model.Unit
+}
+
+func UnitCommandHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req unitCommandReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+	}
+
+	unitID, err := model.NewID(req.UnitID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid UnitID: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+	}
+
+	typ, err := model.NewUnitCommandType(req.Type)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid Type: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+	}
+	
+	playerNode := func(v uint) (*model.Node, error) {
+		id, err := model.NewID(v)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := state.Graphs[sessionID].Node(id)
+		if errors.Is(err, graph.ErrVertexNotFound) {
+			return nil, fmt.Errorf("node not found: %w", err)
+		}
+
+		return n, err
+	}
+
+	var cmd *model.UnitCommand
+	switch typ {
+	case model.MoveUnitCommandType:
+		n, err := playerNode(req.NodeID)
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+		}
+
+		cmd = model.NewMoveUnitCommand(n)
+	case model.WorkUnitCommandType:
+		n, err := playerNode(req.NodeID)
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+		}
+
+		cmd = model.NewWorkUnitCommand(n)
+	case model.HaulUnitCommandType:
+		fromNode, err := playerNode(req.FromNodeID)
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid FromNodeID: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+		}
+
+		toNode, err := playerNode(req.ToNodeID)
+		if err != nil {
+			return sendErrorResp(fmt.Errorf("invalid ToNodeID: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+		}
+		
+		// Zero means that any material can be hauled
+		var matType model.MaterialType
+		if req.MaterialType != 0 {
+			matType, err = model.NewMaterialType(req.MaterialType)
+			if err != nil {
+				return sendErrorResp(fmt.Errorf("invalid MaterialType: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+			}
+		}
+
+		cmd = model.NewHaulUnitCommand(fromNode, toNode, matType)
+	default:
+		panic("unreachable")
+	}
+
+	u, err := state.CommandUnit(sessionID, unitID, cmd)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't command unit: %w", err), dispatcher, opcode.UnitCommand, sessionID, state)
+	}
+	
+	resp := &unitCommandResp{
+		Unit: u,
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.UnitCommand, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type clearUnitCommandReq struct {
+	UnitID uint
+}
+
+type clearUnitCommandResp struct {
+	Unit *model.Unit
+}
+
+func ClearUnitCommandHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req clearUnitCommandReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.ClearUnitCommand, sessionID, state)
+	}
+
+	unitID, err := model.NewID(req.UnitID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid UnitID: %w", err), dispatcher, opcode.ClearUnitCommand, sessionID, state)
+	}
+
+	u, err := state.ClearUnitCommand(sessionID, unitID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't clear unit command: %w", err), dispatcher, opcode.ClearUnitCommand, sessionID, state)
+	}
+	
+	resp := &clearUnitCommandResp{
+		Unit: u,
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.ClearUnitCommand, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
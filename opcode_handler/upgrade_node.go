@@ -0,0 +1,53 @@
+package opcode_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/relby/achikaps/match_state"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+)
+
+type upgradeNodeReq struct {
+	NodeID uint
+}
+
+type upgradeNodeResp struct {
+	Node *model.Node
+}
+
+func UpgradeNodeHandler(dispatcher runtime.MatchDispatcher, msg runtime.MatchData, state *match_state.State) error {
+	sessionID := msg.GetSessionId()
+	
+	var req upgradeNodeReq
+	if err := json.Unmarshal(msg.GetData(), &req); err != nil {
+		return sendErrorResp(fmt.Errorf("can't unmarshal data: %w", err), dispatcher, opcode.UpgradeNode, sessionID, state)
+	}
+
+	nodeID, err := model.NewID(req.NodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("invalid NodeID: %w", err), dispatcher, opcode.UpgradeNode, sessionID, state)
+	}
+
+	n, err := state.UpgradeNode(sessionID, nodeID)
+	if err != nil {
+		return sendErrorResp(fmt.Errorf("can't upgrade node: %w", err), dispatcher, opcode.UpgradeNode, sessionID, state)
+	}
+	
+	resp := &upgradeNodeResp{
+		Node: n,
+	}
+	
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("can't marshal resp: %w", err)
+	}
+
+	if err := sendResp(dispatcher, opcode.UpgradeNode, respBytes, sessionID, state); err != nil {
+		return err
+	}
+
+	return nil
+}
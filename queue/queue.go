@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"errors"
+
+	"github.com/relby/achikaps/rules"
+)
+
+type Queue string
+
+const (
+	RankedDuelQueue Queue = "ranked_1v1"
+	CasualFFAQueue Queue = "casual_ffa"
+	TeamsQueue Queue = "teams_2v2"
+)
+
+// Players that don't choose a queue play casual matches
+const DefaultQueue = CasualFFAQueue
+
+func NewQueue(v string) (Queue, error) {
+	switch v := Queue(v); v {
+	case RankedDuelQueue,
+		CasualFFAQueue,
+		TeamsQueue:
+		return v, nil
+	}
+
+	return "", errors.New("invalid queue")
+}
+
+// Config describes matches of the queue
+type Config struct {
+	MinCount int
+	MaxCount int
+	// Number of players in the match should be a multiple of it
+	CountMultiple int
+	// Results of ranked matches change ratings, players are matched by rating
+	IsRanked bool
+	// Number of players in a team, 1 means that everyone plays for themselves
+	TeamSize int
+}
+
+var configs = map[Queue]*Config{
+	RankedDuelQueue: {
+		MinCount: 2,
+		MaxCount: 2,
+		CountMultiple: 1,
+		IsRanked: true,
+		TeamSize: 1,
+	},
+	CasualFFAQueue: {
+		MinCount: 4,
+		MaxCount: 6,
+		CountMultiple: 1,
+		IsRanked: false,
+		TeamSize: 1,
+	},
+	TeamsQueue: {
+		MinCount: 4,
+		MaxCount: 4,
+		CountMultiple: 2,
+		IsRanked: false,
+		TeamSize: 2,
+	},
+}
+
+func (q Queue) Config() *Config {
+	c, ok := configs[q]
+	if !ok {
+		panic("unreachable")
+	}
+
+	return c
+}
+
+// Rules returns rules of the matches of the queue
+func (q Queue) Rules() *rules.Rules {
+	r := rules.Default()
+
+	switch q {
+	case RankedDuelQueue:
+		// Opponents are closer, so the map is smaller
+		r.PlayersStartRadius = 20.0
+	case CasualFFAQueue:
+		r.PlayersStartRadius = 40.0
+	case TeamsQueue:
+		r.PlayersStartRadius = 30.0
+		r.TeamSize = uint(q.Config().TeamSize)
+		// Allies share vision, enemies are hidden
+		r.FogOfWar = true
+	default:
+		panic("unreachable")
+	}
+
+	return r
+}
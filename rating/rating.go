@@ -0,0 +1,144 @@
+package rating
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	Collection = "ratings"
+	Key = "elo"
+
+	// Rating of players that haven't played rated matches yet
+	Default = 1500.0
+	k = 32.0
+)
+
+// Matchmaker looks for opponents whose rating differs by at most the window,
+// the window widens while the player is waiting
+const (
+	windowBase = 100.0
+	windowGrowthPerSecond = 10.0
+	WindowMax = 600.0
+)
+
+// Rating is stored for every user, only the server can write it
+type Rating struct {
+	Value float64
+	Matches uint
+}
+
+func New() *Rating {
+	return &Rating{
+		Default,
+		0,
+	}
+}
+
+// Change is the result of a rated match for the player
+type Change struct {
+	Old float64
+	New float64
+}
+
+// Window returns the maximum rating difference after waiting for the number of seconds
+func Window(waitingSec float64) float64 {
+	return math.Min(windowBase + windowGrowthPerSecond * math.Max(waitingSec, 0), WindowMax)
+}
+
+// Update applies the result of the match, the winner wins against every other player
+func Update(ratings map[string]*Rating, winner string) map[string]*Change {
+	w, ok := ratings[winner]
+	if !ok {
+		return nil
+	}
+
+	out := make(map[string]*Change, len(ratings))
+	for id, r := range ratings {
+		out[id] = &Change{r.Value, r.Value}
+	}
+
+	// Gain of the winner doesn't depend on the number of players
+	n := float64(len(ratings) - 1)
+	for id, r := range ratings {
+		if id == winner {
+			continue
+		}
+
+		expected := 1.0 / (1.0 + math.Pow(10, (r.Value - w.Value) / 400.0))
+		delta := k * (1.0 - expected) / n
+
+		out[winner].New += delta
+		out[id].New -= delta
+	}
+
+	for id, r := range ratings {
+		r.Value = out[id].New
+		r.Matches += 1
+	}
+
+	return out
+}
+
+// Load reads ratings of users, users without a stored rating get the default one
+func Load(ctx context.Context, nk runtime.NakamaModule, userIDs []string) (map[string]*Rating, error) {
+	reads := make([]*runtime.StorageRead, 0, len(userIDs))
+	for _, userID := range userIDs {
+		reads = append(reads, &runtime.StorageRead{
+			Collection: Collection,
+			Key: Key,
+			UserID: userID,
+		})
+	}
+
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return nil, fmt.Errorf("can't read ratings: %w", err)
+	}
+
+	out := make(map[string]*Rating, len(userIDs))
+	for _, userID := range userIDs {
+		out[userID] = New()
+	}
+
+	for _, o := range objects {
+		r := New()
+		if err := json.Unmarshal([]byte(o.GetValue()), r); err != nil {
+			return nil, fmt.Errorf("can't unmarshal rating: %w", err)
+		}
+
+		out[o.GetUserId()] = r
+	}
+
+	return out, nil
+}
+
+// Save writes ratings of users, everyone can read them
+func Save(ctx context.Context, nk runtime.NakamaModule, ratings map[string]*Rating) error {
+	writes := make([]*runtime.StorageWrite, 0, len(ratings))
+	for userID, r := range ratings {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("can't marshal rating: %w", err)
+		}
+
+		writes = append(writes, &runtime.StorageWrite{
+			Collection: Collection,
+			Key: Key,
+			UserID: userID,
+			Value: string(b),
+			PermissionRead: 2,
+			PermissionWrite: 0,
+		})
+	}
+
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		return fmt.Errorf("can't write ratings: %w", err)
+	}
+
+	return nil
+}
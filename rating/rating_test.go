@@ -0,0 +1,89 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdateEqualRatings(t *testing.T) {
+	ratings := map[string]*Rating{
+		"a": New(),
+		"b": New(),
+	}
+
+	changes := Update(ratings, "a")
+
+	if got, want := changes["a"].New - changes["a"].Old, k / 2; math.Abs(got - want) > 1e-9 {
+		t.Errorf("winner gain = %v, want %v", got, want)
+	}
+	if got, want := changes["b"].New - changes["b"].Old, -k / 2; math.Abs(got - want) > 1e-9 {
+		t.Errorf("loser loss = %v, want %v", got, want)
+	}
+
+	for id, r := range ratings {
+		if r.Value != changes[id].New {
+			t.Errorf("rating of %s = %v, want %v", id, r.Value, changes[id].New)
+		}
+		if r.Matches != 1 {
+			t.Errorf("matches of %s = %d, want 1", id, r.Matches)
+		}
+	}
+}
+
+func TestUpdateKeepsSum(t *testing.T) {
+	ratings := map[string]*Rating{
+		"a": {1400, 3},
+		"b": {1600, 5},
+		"c": {1500, 0},
+	}
+
+	Update(ratings, "a")
+
+	sum := 0.0
+	for _, r := range ratings {
+		sum += r.Value
+	}
+	if math.Abs(sum - 4500) > 1e-9 {
+		t.Errorf("sum of ratings = %v, want 4500", sum)
+	}
+}
+
+func TestUpdateUnderdogGainsMore(t *testing.T) {
+	favorite := Update(map[string]*Rating{"a": {1700, 0}, "b": {1300, 0}}, "a")
+	underdog := Update(map[string]*Rating{"a": {1300, 0}, "b": {1700, 0}}, "a")
+
+	favoriteGain := favorite["a"].New - favorite["a"].Old
+	underdogGain := underdog["a"].New - underdog["a"].Old
+	if underdogGain <= favoriteGain {
+		t.Errorf("underdog gain %v should be greater than favorite gain %v", underdogGain, favoriteGain)
+	}
+}
+
+func TestUpdateUnknownWinner(t *testing.T) {
+	ratings := map[string]*Rating{"a": New()}
+
+	if changes := Update(ratings, "b"); changes != nil {
+		t.Errorf("changes = %v, want nil", changes)
+	}
+	if ratings["a"].Matches != 0 {
+		t.Errorf("matches = %d, want 0", ratings["a"].Matches)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		waitingSec float64
+		want float64
+	}{
+		{-5, windowBase},
+		{0, windowBase},
+		{10, windowBase + 10 * windowGrowthPerSecond},
+		{1000, WindowMax},
+	}
+
+	for _, tt := range tests {
+		if got := Window(tt.waitingSec); got != tt.want {
+			t.Errorf("Window(%v) = %v, want %v", tt.waitingSec, got, tt.want)
+		}
+	}
+}
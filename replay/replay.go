@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/rules"
+)
+
+// Command is a client message accepted by the match
+type Command struct {
+	// Number of state ticks that were executed before the command
+	Tick int64
+	// Order of the command among all commands and signals of the match
+	Seq int64
+	OpCode opcode.OpCode
+	SessionID string
+	Data []byte
+}
+
+// Signal is an admin command that changed the match
+type Signal struct {
+	// Number of state ticks that were executed before the signal
+	Tick int64
+	// Order of the signal among all commands and signals of the match
+	Seq int64
+	Data string
+}
+
+// PauseExpiry is the pause of a player that was resumed on timeout
+type PauseExpiry struct {
+	// Number of state ticks that were executed before the pause expired
+	Tick int64
+	// Order of the expiry among all entries of the match
+	Seq int64
+}
+
+// Entry is either a command, a signal or a pause expiry
+type Entry struct {
+	Command *Command
+	Signal *Signal
+	PauseExpiry *PauseExpiry
+}
+
+func (e *Entry) seq() int64 {
+	switch {
+	case e.Command != nil:
+		return e.Command.Seq
+	case e.Signal != nil:
+		return e.Signal.Seq
+	default:
+		return e.PauseExpiry.Seq
+	}
+}
+
+// Replay is everything that is needed to run the match again,
+// the simulation is deterministic so the same seed and commands give the same match
+type Replay struct {
+	Seed uint64
+	Rules *rules.Rules
+	SessionIDs []string
+	Factions map[string]model.Faction
+	Commands []*Command
+	Signals []*Signal
+	PauseExpiries []*PauseExpiry
+	EndTick int64
+	// Sequence number of the next command or signal
+	NextSeq int64
+}
+
+func New(seed uint64, r *rules.Rules, sessionIDs []string, factions map[string]model.Faction) *Replay {
+	return &Replay{
+		seed,
+		r,
+		slices.Clone(sessionIDs),
+		maps.Clone(factions),
+		make([]*Command, 0),
+		make([]*Signal, 0),
+		make([]*PauseExpiry, 0),
+		0,
+		0,
+	}
+}
+
+func (r *Replay) Record(tick int64, opCode opcode.OpCode, sessionID string, data []byte) {
+	r.Commands = append(r.Commands, &Command{
+		tick,
+		r.NextSeq,
+		opCode,
+		sessionID,
+		slices.Clone(data),
+	})
+	r.NextSeq += 1
+}
+
+func (r *Replay) RecordSignal(tick int64, data string) {
+	r.Signals = append(r.Signals, &Signal{
+		tick,
+		r.NextSeq,
+		data,
+	})
+	r.NextSeq += 1
+}
+
+func (r *Replay) RecordPauseExpiry(tick int64) {
+	r.PauseExpiries = append(r.PauseExpiries, &PauseExpiry{
+		tick,
+		r.NextSeq,
+	})
+	r.NextSeq += 1
+}
+
+// EntriesByTick groups commands, signals and pause expiries by the tick they should be applied before,
+// entries of the tick are in the order they were recorded
+func (r *Replay) EntriesByTick() map[int64][]*Entry {
+	out := make(map[int64][]*Entry)
+	for _, s := range r.Signals {
+		out[s.Tick] = append(out[s.Tick], &Entry{Signal: s})
+	}
+	for _, c := range r.Commands {
+		out[c.Tick] = append(out[c.Tick], &Entry{Command: c})
+	}
+	for _, e := range r.PauseExpiries {
+		out[e.Tick] = append(out[e.Tick], &Entry{PauseExpiry: e})
+	}
+	
+	for _, entries := range out {
+		slices.SortFunc(entries, func(a, b *Entry) int {
+			return cmp.Compare(a.seq(), b.seq())
+		})
+	}
+	
+	return out
+}
+
+func Load(path string) (*Replay, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read replay file: %w", err)
+	}
+
+	// Rules are validated the same way as the rules file
+	var r struct {
+		Replay
+		Rules json.RawMessage
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("can't unmarshal replay: %w", err)
+	}
+	
+	if r.Replay.Rules, err = rules.Parse(r.Rules); err != nil {
+		return nil, fmt.Errorf("invalid replay rules: %w", err)
+	}
+	
+	return &r.Replay, nil
+}
@@ -0,0 +1,90 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relby/achikaps/opcode"
+	"github.com/relby/achikaps/rules"
+)
+
+func TestEntriesByTickKeepRecordedOrder(t *testing.T) {
+	r := New(1, rules.Default(), []string{"a", "b"}, nil)
+	r.Record(0, opcode.BuildNode, "a", []byte("{}"))
+	r.RecordSignal(0, "signal")
+	r.RecordPauseExpiry(0)
+	r.Record(0, opcode.Pause, "b", []byte("{}"))
+	r.Record(3, opcode.Resume, "a", []byte("{}"))
+
+	if r.NextSeq != 5 {
+		t.Fatalf("NextSeq = %d, want 5", r.NextSeq)
+	}
+
+	entries := r.EntriesByTick()
+	if len(entries) != 2 {
+		t.Fatalf("ticks with entries = %d, want 2", len(entries))
+	}
+
+	first := entries[0]
+	if len(first) != 4 {
+		t.Fatalf("entries of the tick 0 = %d, want 4", len(first))
+	}
+	if first[0].Command == nil || first[0].Command.OpCode != opcode.BuildNode {
+		t.Errorf("entry 0 should be the BuildNode command")
+	}
+	if first[1].Signal == nil || first[1].Signal.Data != "signal" {
+		t.Errorf("entry 1 should be the signal")
+	}
+	if first[2].PauseExpiry == nil {
+		t.Errorf("entry 2 should be the pause expiry")
+	}
+	if first[3].Command == nil || first[3].Command.OpCode != opcode.Pause {
+		t.Errorf("entry 3 should be the Pause command")
+	}
+
+	if len(entries[3]) != 1 || entries[3][0].Command.Seq != 4 {
+		t.Errorf("tick 3 should have the Resume command with sequence number 4")
+	}
+}
+
+func TestRecordClonesData(t *testing.T) {
+	r := New(1, rules.Default(), []string{"a"}, nil)
+	data := []byte("{}")
+	r.Record(0, opcode.BuildNode, "a", data)
+	data[0] = 'x'
+
+	if string(r.Commands[0].Data) != "{}" {
+		t.Errorf("recorded data = %s, want {}", r.Commands[0].Data)
+	}
+}
+
+func TestLoadValidatesRules(t *testing.T) {
+	tests := []struct {
+		name string
+		replay string
+		wantErr bool
+	}{
+		{"valid rules", `{"Seed": 1, "Rules": {"TeamSize": 2}, "SessionIDs": ["a"]}`, false},
+		{"null win condition", `{"Seed": 1, "Rules": {"WinCondition": null}, "SessionIDs": ["a"]}`, true},
+		{"zero team size", `{"Seed": 1, "Rules": {"TeamSize": 0}, "SessionIDs": ["a"]}`, true},
+		{"missing rules", `{"Seed": 1, "SessionIDs": ["a"]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "replay.json")
+			if err := os.WriteFile(path, []byte(tt.replay), 0o644); err != nil {
+				t.Fatalf("can't write replay: %v", err)
+			}
+
+			r, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && r.Rules.TeamSize != 2 {
+				t.Errorf("TeamSize = %d, want 2", r.Rules.TeamSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/relby/achikaps/config"
+	"github.com/relby/achikaps/model"
+	"github.com/relby/achikaps/win_condition"
+)
+
+// Rules describe how a match starts and how it's won
+type Rules struct {
+	PlayersStartRadius float64
+	// Transit nodes that are already built around the root node
+	StartTransitNodes uint
+	// Starting units of every type for each player
+	Units map[model.UnitType]uint
+	// Starting materials of every type for each player
+	Materials map[model.MaterialType]uint
+	WinCondition *win_condition.WinCondition
+	// Number of pauses every player can make
+	Pauses uint
+	// Pause of a player is resumed automatically after this time
+	MaxPauseMs uint
+	// Multiplier of the game time, used in sandbox and tutorial modes
+	GameSpeed float64
+	// Players go to teams of this size in the order of session IDs
+	TeamSize uint
+	// Players see only updates of their team
+	FogOfWar bool
+	// Map features generated around every player
+	Obstacles uint
+	Deposits uint
+	// Neutral sites between every pair of neighbouring players
+	NeutralSites uint
+}
+
+func Default() *Rules {
+	materials := make(map[model.MaterialType]uint, len(MaterialTypes))
+	for _, t := range MaterialTypes {
+		materials[t] = 30
+	}
+
+	return &Rules{
+		PlayersStartRadius: config.PlayersStartRadius,
+		StartTransitNodes: 2,
+		Units: map[model.UnitType]uint{
+			model.IdleUnitType: 4,
+			model.BuilderUnitType: 4,
+			model.ProductionUnitType: 4,
+			model.TransportUnitType: 4,
+		},
+		Materials: materials,
+		WinCondition: win_condition.New(model.JuiceMaterialType, 100),
+		Pauses: 3,
+		MaxPauseMs: 60_000,
+		GameSpeed: 1.0,
+		TeamSize: 1,
+		FogOfWar: false,
+		Obstacles: 3,
+		Deposits: 3,
+		NeutralSites: 1,
+	}
+}
+
+// Parse reads rules from JSON, fields that are not set keep their default values
+func Parse(b []byte) (*Rules, error) {
+	r := Default()
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("can't unmarshal rules: %w", err)
+	}
+
+	for t := range r.Units {
+		if _, err := model.NewUnitType(uint(t)); err != nil {
+			return nil, fmt.Errorf("invalid Units: %w", err)
+		}
+	}
+
+	for t := range r.Materials {
+		if _, err := model.NewMaterialType(uint(t)); err != nil {
+			return nil, fmt.Errorf("invalid Materials: %w", err)
+		}
+	}
+	
+	if r.WinCondition == nil {
+		return nil, fmt.Errorf("invalid WinCondition: should be set")
+	}
+	if _, err := model.NewMaterialType(uint(r.WinCondition.MaterialType)); err != nil {
+		return nil, fmt.Errorf("invalid WinCondition: %w", err)
+	}
+	
+	if r.TeamSize == 0 {
+		return nil, fmt.Errorf("invalid TeamSize: should be positive")
+	}
+	
+	if r.GameSpeed < config.MinGameSpeed || r.GameSpeed > config.MaxGameSpeed {
+		return nil, fmt.Errorf("invalid GameSpeed: should be in range from %v to %v", config.MinGameSpeed, config.MaxGameSpeed)
+	}
+
+	return r, nil
+}
+
+func Load(path string) (*Rules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read rules file: %w", err)
+	}
+
+	return Parse(b)
+}
+
+// Units are created in this order, so their IDs are the same for every player
+var UnitTypes = []model.UnitType{
+	model.IdleUnitType,
+	model.BuilderUnitType,
+	model.ProductionUnitType,
+	model.TransportUnitType,
+}
+
+// Materials are created in this order, so their IDs are the same for every player
+var MaterialTypes = []model.MaterialType{
+	model.GrassMaterialType,
+	model.SandMaterialType,
+	model.DewMaterialType,
+	model.SeedMaterialType,
+	model.SugarMaterialType,
+	model.JuiceMaterialType,
+	model.ChitinMaterialType,
+	model.EggMaterialType,
+	model.PheromoneMaterialType,
+	model.AmberMaterialType,
+}
@@ -0,0 +1,138 @@
+package tech_tree
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/relby/achikaps/assert"
+	"github.com/relby/achikaps/model"
+)
+
+//go:embed tech_tree.json
+var defaultTechTree []byte
+
+// Tech is researched at a research node, it unlocks building of nodes
+type Tech struct {
+	ID model.ID
+	Name string
+	// Techs that have to be researched before
+	Requires []model.ID
+	// Materials that are brought to the research node and consumed when the research starts
+	Materials map[model.MaterialType]uint
+	// Game time of the research after materials are consumed
+	TimeMs float64
+	Unlocks []model.NodeName
+}
+
+type TechTree struct {
+	// Nodes that can be built without research
+	Unlocked []model.NodeName
+	Techs []*Tech
+}
+
+// Default returns the tech tree that is used in every match
+func Default() *TechTree {
+	t, err := Parse(defaultTechTree)
+	assert.NoError(err)
+
+	return t
+}
+
+// Parse reads the tech tree from JSON and checks that every tech can be researched
+func Parse(b []byte) (*TechTree, error) {
+	var t TechTree
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("can't unmarshal tech tree: %w", err)
+	}
+
+	for _, name := range t.Unlocked {
+		if _, err := model.NewNodeName(uint(name)); err != nil {
+			return nil, fmt.Errorf("invalid Unlocked: %w", err)
+		}
+	}
+
+	// Techs only require techs that are defined before them, so there are no cycles
+	seen := make(map[model.ID]bool, len(t.Techs))
+	for _, tech := range t.Techs {
+		if _, err := model.NewID(uint(tech.ID)); err != nil {
+			return nil, fmt.Errorf("invalid ID: %w", err)
+		}
+
+		if seen[tech.ID] {
+			return nil, fmt.Errorf("tech with id %d is defined twice", tech.ID)
+		}
+
+		for _, id := range tech.Requires {
+			if !seen[id] {
+				return nil, fmt.Errorf("tech with id %d requires tech with id %d that is not defined before it", tech.ID, id)
+			}
+		}
+
+		if len(tech.Materials) == 0 {
+			return nil, fmt.Errorf("tech with id %d doesn't cost any materials", tech.ID)
+		}
+
+		for typ := range tech.Materials {
+			if _, err := model.NewMaterialType(uint(typ)); err != nil {
+				return nil, fmt.Errorf("invalid Materials of tech with id %d: %w", tech.ID, err)
+			}
+		}
+
+		if tech.TimeMs <= 0 {
+			return nil, fmt.Errorf("invalid TimeMs of tech with id %d: should be positive", tech.ID)
+		}
+
+		for _, name := range tech.Unlocks {
+			if _, err := model.NewNodeName(uint(name)); err != nil {
+				return nil, fmt.Errorf("invalid Unlocks of tech with id %d: %w", tech.ID, err)
+			}
+		}
+
+		seen[tech.ID] = true
+	}
+
+	return &t, nil
+}
+
+func (t *TechTree) Tech(id model.ID) (*Tech, bool) {
+	i := slices.IndexFunc(t.Techs, func(tech *Tech) bool {
+		return tech.ID == id
+	})
+	if i == -1 {
+		return nil, false
+	}
+
+	return t.Techs[i], true
+}
+
+// IsUnlocked checks if the node can be built after the techs are researched
+func (t *TechTree) IsUnlocked(name model.NodeName, researched map[model.ID]bool) bool {
+	if slices.Contains(t.Unlocked, name) {
+		return true
+	}
+
+	for _, tech := range t.Techs {
+		if researched[tech.ID] && slices.Contains(tech.Unlocks, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAvailable checks if the tech can be researched after the techs are researched
+func (t *TechTree) IsAvailable(tech *Tech, researched map[model.ID]bool) bool {
+	if researched[tech.ID] {
+		return false
+	}
+
+	for _, id := range tech.Requires {
+		if !researched[id] {
+			return false
+		}
+	}
+
+	return true
+}
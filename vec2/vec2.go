@@ -26,6 +26,34 @@ func Distance(a Vec2, b Vec2) float64 {
 	return math.Sqrt(dx*dx + dy*dy)
 }
 
+// DistanceToSegment returns the distance from the point to the closest point of the segment
+func DistanceToSegment(p Vec2, a Vec2, b Vec2) float64 {
+	ab := b.Sub(a)
+	lengthSq := ab.Dot(ab)
+	if lengthSq == 0 {
+		return Distance(p, a)
+	}
+
+	t := math.Max(0, math.Min(1, p.Sub(a).Dot(ab)/lengthSq))
+
+	return Distance(p, a.Lerp(b, t))
+}
+
+// SegmentsIntersect checks if the segment ab crosses the segment cd, touching ends don't count
+func SegmentsIntersect(a Vec2, b Vec2, c Vec2, d Vec2) bool {
+	cross := func(o, p, q Vec2) float64 {
+		return (p.X-o.X)*(q.Y-o.Y) - (p.Y-o.Y)*(q.X-o.X)
+	}
+
+	d1 := cross(c, d, a)
+	d2 := cross(c, d, b)
+	d3 := cross(a, b, c)
+	d4 := cross(a, b, d)
+
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
 func Reflect(ihs Vec2, rhs Vec2) Vec2 {
 	factor := -2.0 * Dot(ihs, rhs)
 	return New(